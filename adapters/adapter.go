@@ -2,12 +2,33 @@ package adapters
 
 import (
 	"NeighBot/llm"
+	"NeighBot/metrics"
+	"go.uber.org/zap"
 )
 
 type ChatAdapterConfig struct {
-	Enabled     bool              `json:"enabled"`
-	MemoryStore *llm.MemoryStore  `json:"-"`
-	LLMClient   *llm.OpenAIClient `json:"-"`
+	Type        string             `json:"type"` // Registered adapter type, defaults to the instance name
+	Enabled     bool               `json:"enabled"`
+	Name        string             `json:"-"` // Instance name, set from the config key
+	MemoryStore *llm.MemoryStore   `json:"-"`
+	LLMClient   llm.Provider       `json:"-"`
+	Logger      *zap.SugaredLogger `json:"-"`
+	Metrics     *metrics.Metrics   `json:"-"`
+}
+
+func (c *ChatAdapterConfig) Identity() AdapterIdentity {
+	return AdapterIdentity{Type: c.Type, Name: c.Name}
+}
+
+// AdapterIdentity identifies a configured adapter instance.
+// Several instances may share the same adapter type.
+type AdapterIdentity struct {
+	Type string
+	Name string
+}
+
+func (id AdapterIdentity) String() string {
+	return id.Name
 }
 
 // ChatAdapter is a common interface all adapters must implement
@@ -16,5 +37,9 @@ type ChatAdapter interface {
 	Initialize() error
 	Start() error
 	Stop() error
-	AdapterName() string
+	Identity() AdapterIdentity
+
+	// Failed returns a channel receiving an error once the adapter stops
+	// working after Start succeeded, e.g. when it loses its connection.
+	Failed() <-chan error
 }
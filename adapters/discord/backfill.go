@@ -0,0 +1,117 @@
+package discord
+
+import (
+	"NeighBot/adapters"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"slices"
+)
+
+const (
+	// defaultBackfill is how many missed messages are fetched per channel if
+	// not configured.
+	defaultBackfill = 100
+
+	// maxFetchMessages is Discord's limit of messages per history request.
+	maxFetchMessages = 100
+)
+
+// readyHandler fetches the messages missed while disconnected, on startup
+// and whenever a new session is established.
+func (d *DiscordAdapter) readyHandler(s *discordgo.Session, _ *discordgo.Ready) {
+	limit := d.backfillLimit()
+	if limit <= 0 {
+		return
+	}
+	for _, channelID := range d.pipeline.Chats() {
+		if err := d.backfill(s, channelID, limit); err != nil {
+			d.config.Logger.Warnw("Failed to backfill channel", "channel_id", channelID, "error", err)
+		}
+	}
+}
+
+// backfill stores up to limit of the latest messages sent in the channel
+// since the last stored one. Channels without stored messages are skipped,
+// there is nothing to resume from.
+func (d *DiscordAdapter) backfill(s *discordgo.Session, channelID string, limit int) error {
+	ctx := d.pipeline.Route(channelID)
+	if ctx == nil {
+		return nil
+	}
+	last := ctx.LastMessageID(channelID)
+	if last == "" {
+		return nil
+	}
+
+	channel, err := d.channel(s, channelID)
+	if err != nil {
+		return err
+	}
+
+	// Page back from the latest message until the last stored one
+	var missed []*discordgo.Message
+	before := ""
+	for done := false; !done; {
+		batch, err := s.ChannelMessages(channelID, maxFetchMessages, before, "", "")
+		if err != nil {
+			return fmt.Errorf("fetch messages: %w", err)
+		}
+		done = len(batch) < maxFetchMessages
+		for _, m := range batch {
+			if !newerID(m.ID, last) {
+				done = true
+				break
+			}
+			missed = append(missed, m)
+			before = m.ID
+			if len(missed) == limit {
+				done = true
+				break
+			}
+		}
+	}
+
+	// Messages are fetched newest first
+	slices.Reverse(missed)
+	msgs := make([]adapters.InboundMessage, 0, len(missed))
+	for _, m := range missed {
+		// The bot's own responses were stored when sent
+		if m.Author == nil || m.Author.ID == s.State.User.ID {
+			continue
+		}
+		// Fetched messages lack the guild ID
+		m.GuildID = channel.GuildID
+		if !d.contentVisible(s, m) {
+			continue
+		}
+		msg, err := d.inbound(s, m)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	_, err = d.pipeline.Backfill(ctx, msgs)
+	return err
+}
+
+// backfillLimit returns how many messages to fetch per channel, 0 if
+// backfilling is disabled.
+func (d *DiscordAdapter) backfillLimit() int {
+	switch {
+	case d.config.MaxBackfill < 0:
+		return 0
+	case d.config.MaxBackfill == 0:
+		return defaultBackfill
+	default:
+		return d.config.MaxBackfill
+	}
+}
+
+// newerID reports whether snowflake a was created after snowflake b.
+func newerID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
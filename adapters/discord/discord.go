@@ -2,26 +2,57 @@ package discord
 
 import (
 	"NeighBot/adapters"
-	"NeighBot/logger"
+	"NeighBot/llm"
+	"NeighBot/markdown"
+	"NeighBot/triggers"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
+	"path"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxAllowedUsers is Discord's limit of users in allowed mentions.
+	maxAllowedUsers = 100
+
+	// maxMessageLength is Discord's limit of characters in a message.
+	maxMessageLength = 2000
+
+	// typingInterval renews the typing indicator before Discord clears it.
+	typingInterval = 8 * time.Second
 )
 
 type DiscordConfig struct {
 	adapters.ChatAdapterConfig
-	Token string `json:"token"`
+	Token            string   `json:"token"`
+	DirectMessages   bool     `json:"direct_messages"`    // Answer DMs, creating a context per user
+	AutoThreadChars  int      `json:"auto_thread_chars"`  // Replies longer than this open a thread, 0 disables
+	MaxMessageLength int      `json:"max_message_length"` // Characters per message before splitting, at most and by default 2000
+	PingOnReply      bool     `json:"ping_on_reply"`      // Notify the user replied to
+	MaxBackfill      int      `json:"max_backfill"`       // Missed messages fetched per channel on connect, defaults to 100, negative disables
+	Intents          []string `json:"intents"`            // Gateway intents, e.g. "message_content", defaults to guilds, guild_messages and message_content
 }
 
 type DiscordAdapter struct {
-	config  DiscordConfig
-	session *discordgo.Session
-}
+	config   DiscordConfig
+	session  *discordgo.Session
+	pipeline *adapters.Pipeline
+
+	members *memberIndex
 
-var responding = false
-var usersCache = make(map[string]*discordgo.User)
+	// Without the message content intent, Discord only sends the content of
+	// messages mentioning the bot, so only those are handled
+	mentionOnly bool
+
+	mu       sync.Mutex
+	failed   chan error // Reports losing the connection to the supervisor
+	stopping bool
+}
 
 func (d *DiscordAdapter) SetConfig(cfg interface{}) error {
 	c, ok := cfg.(*DiscordConfig)
@@ -29,6 +60,7 @@ func (d *DiscordAdapter) SetConfig(cfg interface{}) error {
 		return errors.New("invalid config type for DiscordAdapter")
 	}
 	d.config = *c
+	d.members = newMemberIndex()
 	return nil
 }
 
@@ -36,6 +68,10 @@ func (d *DiscordAdapter) Initialize() error {
 	if d.config.Token == "" {
 		return errors.New("discord token is required")
 	}
+	intents, err := d.intents()
+	if err != nil {
+		return err
+	}
 
 	session, err := discordgo.New("Bot " + d.config.Token)
 	if err != nil {
@@ -43,10 +79,26 @@ func (d *DiscordAdapter) Initialize() error {
 	}
 
 	d.session = session
+	d.pipeline = adapters.NewPipeline(&d.config.ChatAdapterConfig)
+	// The supervisor reconnects with its backoff, after Failed reports the disconnect
+	d.session.ShouldReconnectOnError = false
+	d.session.AddHandler(d.readyHandler)
+	d.session.AddHandler(d.disconnectHandler)
 	d.session.AddHandler(d.messageCreateHandler)
-	d.session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages
+	d.session.AddHandler(d.messageUpdateHandler)
+	d.session.AddHandler(d.messageDeleteHandler)
+	d.session.AddHandler(d.messageDeleteBulkHandler)
+	d.session.AddHandler(d.reactionAddHandler)
+	d.session.AddHandler(d.reactionRemoveHandler)
+	d.session.Identify.Intents = intents
+	d.mentionOnly = intents&discordgo.IntentMessageContent == 0
+	if d.mentionOnly {
+		d.config.Logger.Warnw("Message content intent not requested, only handling messages mentioning the bot",
+			"adapter", d.Identity().Name,
+		)
+	}
 
-	logger.Sugar.Infow("Discord session initialized", "adapter", d.AdapterName())
+	d.config.Logger.Infow("Discord session initialized", "adapter", d.Identity().Name)
 	return nil
 }
 
@@ -55,11 +107,26 @@ func (d *DiscordAdapter) Start() error {
 		return errors.New("discord session not initialized")
 	}
 
-	if err := d.session.Open(); err != nil {
-		return err
+	d.mu.Lock()
+	d.failed = make(chan error, 1)
+	d.stopping = false
+	d.mu.Unlock()
+
+	err := d.session.Open()
+	if closeCode(err) == closeDisallowedIntents && d.session.Identify.Intents&discordgo.IntentMessageContent != 0 {
+		// Degrade to handling mentions rather than not connecting at all
+		d.config.Logger.Warnw("Message content intent refused, retrying without it and only handling messages mentioning the bot",
+			"adapter", d.Identity().Name,
+		)
+		d.session.Identify.Intents &^= discordgo.IntentMessageContent
+		d.mentionOnly = true
+		err = d.session.Open()
+	}
+	if err != nil {
+		return intentsError(err, d.session.Identify.Intents)
 	}
 
-	logger.Sugar.Infow("Discord connection established", "adapter", d.AdapterName())
+	d.config.Logger.Infow("Discord connection established", "adapter", d.Identity().Name)
 	return nil
 }
 
@@ -68,16 +135,39 @@ func (d *DiscordAdapter) Stop() error {
 		return errors.New("discord session not initialized")
 	}
 
+	d.mu.Lock()
+	d.stopping = true
+	d.mu.Unlock()
 	if err := d.session.Close(); err != nil {
 		return err
 	}
 
-	logger.Sugar.Infow("Discord connection closed", "adapter", d.AdapterName())
+	d.config.Logger.Infow("Discord connection closed", "adapter", d.Identity().Name)
 	return nil
 }
 
-func (d *DiscordAdapter) AdapterName() string {
-	return "discord"
+func (d *DiscordAdapter) Identity() adapters.AdapterIdentity {
+	return d.config.Identity()
+}
+
+func (d *DiscordAdapter) Failed() <-chan error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failed
+}
+
+// disconnectHandler reports losing the gateway connection, unless Stop
+// closed it.
+func (d *DiscordAdapter) disconnectHandler(_ *discordgo.Session, _ *discordgo.Disconnect) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopping || d.failed == nil {
+		return
+	}
+	select {
+	case d.failed <- errors.New("disconnected from the discord gateway"):
+	default:
+	}
 }
 
 func (d *DiscordAdapter) messageCreateHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -87,123 +177,348 @@ func (d *DiscordAdapter) messageCreateHandler(s *discordgo.Session, m *discordgo
 	}
 
 	// Fetch context by channel ID (TODO: combine server + channel ID to be sure?)
-	ctx := d.config.MemoryStore.GetContextForChat(m.ChannelID)
+	direct := m.GuildID == ""
+	var ctx *llm.StoredContext
+	if direct {
+		if !d.config.DirectMessages {
+			return
+		}
+		ctx = d.pipeline.RouteDirect(m.ChannelID, m.Author.ID, displayName(m.Author, nil))
+	} else {
+		ctx = d.routeNew(s, m.ChannelID)
+	}
 	if ctx == nil {
 		// Skip unknown chats
 		return
 	}
+	if !d.contentVisible(s, m.Message) {
+		return
+	}
 
-	// Cache the user
-	usersCache[m.Author.GlobalName] = m.Author
+	msg, err := d.inbound(s, m.Message)
+	if err != nil {
+		d.config.Logger.Errorw("Failed to resolve message source", "error", err)
+		return
+	}
+	source := msg.Source
 
-	// Get server and channel names to construct source
-	server, err := s.State.Guild(m.GuildID)
+	// Add user message
+	if err = d.pipeline.Record(ctx, msg); err != nil {
+		if !errors.Is(err, adapters.ErrDropped) {
+			d.config.Logger.Errorw("Failed to add user message", "error", err)
+		}
+		return
+	}
+
+	if !d.pipeline.ShouldRespond(ctx, triggers.Message{
+		Content:    m.Content,
+		Mentioned:  mentions(m.Message, s.State.User.ID),
+		ReplyToBot: m.ReferencedMessage != nil && m.ReferencedMessage.Author != nil && m.ReferencedMessage.Author.ID == s.State.User.ID,
+		Direct:     direct,
+	}) {
+		return
+	}
+
+	// Typing stops once the reply is sent, or generation fails
+	goCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	response, reaction, err := d.pipeline.Respond(goCtx, ctx, source, func() {
+		go d.keepTyping(goCtx, s, m.ChannelID)
+	})
+	if errors.Is(err, adapters.ErrBusy) {
+		return
+	}
 	if err != nil {
-		// Try with GET
-		server, err = s.Guild(m.GuildID)
-		if err != nil {
-			logger.Sugar.Errorw("Failed to get guild", "error", err)
+		d.config.Logger.Errorw("Failed to generate response", "error", err)
+		return
+	}
+
+	// React instead of replying if the model asked to
+	if reaction != "" {
+		if err = s.MessageReactionAdd(m.ChannelID, m.ID, reaction); err == nil {
 			return
 		}
+		d.config.Logger.Errorw("Failed to add reaction, replying instead", "emoji", reaction, "error", err)
+		if response, err = d.pipeline.ReactionFailed(ctx); err != nil {
+			d.config.Logger.Errorw("Failed to replace reaction in memory", "error", err)
+		}
 	}
-	serverName := server.Name
 
-	channel, err := s.State.Channel(m.ChannelID)
-	if err != nil {
-		// Try with GET
-		channel, err = s.Channel(m.ChannelID)
+	// Replace any mentions in response '@user name' with Discord format <@user ID>
+	response = d.members.mentionNames(m.GuildID, response)
+
+	// Only users present in the conversation may be pinged, never roles or everyone
+	allowed := &discordgo.MessageAllowedMentions{
+		Parse:       []discordgo.AllowedMentionType{},
+		Users:       ctx.Participants(maxAllowedUsers),
+		RepliedUser: d.config.PingOnReply,
+	}
+
+	// Reply to the triggering message, unless answering in a new thread
+	channelID := d.replyChannel(s, m, response)
+	var reference *discordgo.MessageReference
+	if channelID == m.ChannelID {
+		reference = m.SoftReference()
+	}
+
+	// Send the response to Discord, split into chunks if too long
+	var sentIDs []string
+	for _, chunk := range markdown.Split(response, d.messageLength()) {
+		sent, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:         chunk,
+			AllowedMentions: allowed,
+			Reference:       reference,
+		})
+		// Only the first chunk replies
+		reference = nil
 		if err != nil {
-			logger.Sugar.Errorw("Failed to get channel", "error", err)
-			return
+			d.config.Logger.Errorw("Failed to send response", "error", err)
+			continue
 		}
+		sentIDs = append(sentIDs, sent.ID)
 	}
-	channelName := channel.Name
 
-	source := fmt.Sprintf("%s:%s:%s", d.AdapterName(), serverName, channelName)
+	// Remember the sent messages, so reactions to them count as feedback
+	if err = d.pipeline.Sent(ctx, sentIDs...); err != nil {
+		d.config.Logger.Errorw("Failed to store sent message IDs", "error", err)
+	}
 
-	// Log the incoming message
-	logger.Sugar.Infow("Incoming message",
-		"author", m.Author.Username,
-		"content", m.Content,
-		"channel_id", m.ChannelID,
-	)
+	// Threads opened for the response start with the question and the answer
+	if channelID != m.ChannelID && len(sentIDs) > 0 {
+		d.pipeline.RouteThread(channelID, m.ChannelID, m.ID, sentIDs[0])
+	}
+}
 
-	// Replace the bot mention in message content with '@NeighBot' for proper formatting
-	formatted := strings.ReplaceAll(m.Content, s.State.User.Mention(), "@NeighBot")
+// inbound converts a message for the pipeline, indexing its author so
+// responses can mention them.
+func (d *DiscordAdapter) inbound(s *discordgo.Session, m *discordgo.Message) (adapters.InboundMessage, error) {
+	username := displayName(m.Author, m.Member)
+	d.members.add(m.GuildID, m.Author.ID, username)
 
-	// Add user message
-	if err = d.config.MemoryStore.AddUserMessage(ctx.ID, source, m.Author.GlobalName, formatted); err != nil {
-		logger.Sugar.Errorw("Failed to add user message", "error", err)
+	source, err := d.source(s, m.GuildID, m.ChannelID)
+	if err != nil {
+		return adapters.InboundMessage{}, err
+	}
+
+	var replyToID string
+	if m.MessageReference != nil {
+		replyToID = m.MessageReference.MessageID
+	}
+
+	return adapters.InboundMessage{
+		ChatID:    m.ChannelID,
+		MessageID: m.ID,
+		ReplyToID: replyToID,
+		Source:    source,
+		UserID:    m.Author.ID,
+		Username:  username,
+		Content:   d.normalizeMarkup(s, m),
+		Images:    images(m),
+		Timestamp: m.Timestamp,
+	}, nil
+}
+
+func (d *DiscordAdapter) messageUpdateHandler(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// Embed unfurls also trigger updates, only follow actual edits
+	if m.Author == nil || m.Author.ID == s.State.User.ID || m.EditedTimestamp == nil {
 		return
 	}
 
-	// Check if the bot is mentioned
-	if !strings.Contains(m.Content, s.State.User.Mention()) {
+	ctx := d.route(s, m.ChannelID)
+	if ctx == nil || !d.contentVisible(s, m.Message) {
 		return
 	}
 
-	// If already responding, skip
-	if responding {
+	source, err := d.source(s, m.GuildID, m.ChannelID)
+	if err != nil {
+		d.config.Logger.Errorw("Failed to resolve message source", "error", err)
 		return
 	}
 
-	// Set typing state
-	if err = s.ChannelTyping(m.ChannelID); err != nil {
-		// Just warn, no need to stop the process
-		logger.Sugar.Warnw("Failed to set typing state", "error", err)
+	if err = d.pipeline.Edit(ctx, adapters.InboundMessage{
+		ChatID:    m.ChannelID,
+		MessageID: m.ID,
+		Source:    source,
+		UserID:    m.Author.ID,
+		Username:  displayName(m.Author, m.Member),
+		Content:   d.normalizeMarkup(s, m.Message),
+	}); err != nil {
+		d.config.Logger.Errorw("Failed to update edited message", "error", err)
 	}
+}
 
-	responding = true
+func (d *DiscordAdapter) messageDeleteHandler(s *discordgo.Session, m *discordgo.MessageDelete) {
+	d.deleteMessages(s, m.ChannelID, m.ID)
+}
 
-	// Generate response from LLM
-	messages := ctx.Messages
-	response, err := d.config.LLMClient.GenerateResponse(context.Background(), messages)
-	if err != nil {
-		logger.Sugar.Errorw("Failed to generate response", "error", err)
-		responding = false
+func (d *DiscordAdapter) messageDeleteBulkHandler(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	d.deleteMessages(s, m.ChannelID, m.Messages...)
+}
+
+func (d *DiscordAdapter) deleteMessages(s *discordgo.Session, channelID string, messageIDs ...string) {
+	ctx := d.route(s, channelID)
+	if ctx == nil {
 		return
 	}
+	if err := d.pipeline.Delete(ctx, messageIDs...); err != nil {
+		d.config.Logger.Errorw("Failed to delete messages", "error", err)
+	}
+}
 
-	responding = false
+// route returns the context of a guild channel. Threads without a context of
+// their own are routed through their parent channel.
+func (d *DiscordAdapter) route(s *discordgo.Session, channelID string) *llm.StoredContext {
+	if ctx := d.pipeline.Route(channelID); ctx != nil {
+		return ctx
+	}
 
-	// Apply filters to the response
-	response = ctx.ApplyFilters(response)
+	channel, err := d.channel(s, channelID)
+	if err != nil || !channel.IsThread() {
+		return nil
+	}
+	return d.pipeline.LookupThread(channelID, channel.ParentID)
+}
 
-	// Log the response
-	logger.Sugar.Infow("Generated response",
-		"content", response,
-		"channel_id", m.ChannelID,
-	)
+// routeNew is route for new messages, deriving a context for threads of
+// channels with separate thread memory.
+func (d *DiscordAdapter) routeNew(s *discordgo.Session, channelID string) *llm.StoredContext {
+	if ctx := d.pipeline.Route(channelID); ctx != nil {
+		return ctx
+	}
 
-	// Add response
-	if err = d.config.MemoryStore.AddAssistantMessage(ctx.ID, source, response); err != nil {
-		logger.Sugar.Errorw("Failed to add assistant message", "error", err)
-		return
+	channel, err := d.channel(s, channelID)
+	if err != nil || !channel.IsThread() {
+		return nil
 	}
+	// Threads started from a message share its ID
+	return d.pipeline.RouteThread(channelID, channel.ParentID, channelID)
+}
 
-	// Replace any mentions in response '@user name' with Discord format <@!user ID>
-	if strings.Contains(response, "@") {
-		for k, v := range usersCache {
-			response = strings.ReplaceAll(response, "@"+k, v.Mention())
+func (d *DiscordAdapter) channel(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		// Try with GET
+		channel, err = s.Channel(channelID)
+		if err != nil {
+			return nil, fmt.Errorf("get channel: %w", err)
 		}
 	}
+	return channel, nil
+}
 
-	// Send the response to Discord, if over 2000 characters, send in chunks
-	if len(response) > 2000 {
-		for i := 0; i < len(response); i += 2000 {
-			end := i + 2000
-			if end > len(response) {
-				end = len(response)
-			}
-			_, err = s.ChannelMessageSend(m.ChannelID, response[i:end])
-			if err != nil {
-				logger.Sugar.Errorw("Failed to send response", "error", err)
-			}
-		}
-	} else {
-		_, err = s.ChannelMessageSend(m.ChannelID, response)
+// replyChannel returns the channel to send a response to m in, opening a
+// thread on m for long responses if enabled.
+func (d *DiscordAdapter) replyChannel(s *discordgo.Session, m *discordgo.MessageCreate, response string) string {
+	if d.config.AutoThreadChars <= 0 || utf8.RuneCountInString(response) <= d.config.AutoThreadChars || m.GuildID == "" {
+		return m.ChannelID
+	}
+	if channel, err := d.channel(s, m.ChannelID); err != nil || channel.IsThread() {
+		return m.ChannelID
+	}
+
+	thread, err := s.MessageThreadStart(m.ChannelID, m.ID, threadName(d.normalizeMarkup(s, m.Message)), 60)
+	if err != nil {
+		d.config.Logger.Warnw("Failed to open thread, replying in channel", "error", err)
+		return m.ChannelID
+	}
+	return thread.ID
+}
+
+// threadName derives a thread name from the message, within Discord's limit.
+func threadName(content string) string {
+	name := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(name); len(runes) > 80 {
+		name = string(runes[:80]) + "…"
+	}
+	if name == "" {
+		name = "Reply"
+	}
+	return name
+}
+
+// source returns "<instance>:<server>:<channel>", or "<instance>:dm:<channel>"
+// for direct messages, naming where a message came from.
+func (d *DiscordAdapter) source(s *discordgo.Session, guildID, channelID string) (string, error) {
+	if guildID == "" {
+		return fmt.Sprintf("%s:dm:%s", d.Identity().Name, channelID), nil
+	}
+
+	// Get server and channel names to construct source
+	server, err := s.State.Guild(guildID)
+	if err != nil {
+		// Try with GET
+		server, err = s.Guild(guildID)
 		if err != nil {
-			logger.Sugar.Errorw("Failed to send response", "error", err)
+			return "", fmt.Errorf("get guild: %w", err)
+		}
+	}
+
+	channel, err := d.channel(s, channelID)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s:%s:%s", d.Identity().Name, server.Name, channel.Name), nil
+}
+
+// mentions reports whether the message @-mentions the user.
+func mentions(m *discordgo.Message, userID string) bool {
+	for _, user := range m.Mentions {
+		if user.ID == userID {
+			return true
 		}
 	}
+	return strings.Contains(m.Content, "<@"+userID+">") || strings.Contains(m.Content, "<@!"+userID+">")
+}
+
+// images returns the images attached or embedded in the message.
+func images(m *discordgo.Message) []adapters.Attachment {
+	var found []adapters.Attachment
+	for _, attachment := range m.Attachments {
+		if strings.HasPrefix(attachment.ContentType, "image/") {
+			found = append(found, adapters.Attachment{
+				URL:      attachment.URL,
+				Filename: attachment.Filename,
+				MimeType: attachment.ContentType,
+			})
+		}
+	}
+	for _, embed := range m.Embeds {
+		if embed.Type != discordgo.EmbedTypeImage || embed.Thumbnail == nil {
+			continue
+		}
+		url := embed.Thumbnail.ProxyURL
+		if url == "" {
+			url = embed.Thumbnail.URL
+		}
+		embedPath, _, _ := strings.Cut(embed.URL, "?")
+		found = append(found, adapters.Attachment{URL: url, Filename: path.Base(embedPath)})
+	}
+	return found
+}
+
+// keepTyping shows the bot typing in the channel until ctx is done. Discord
+// clears the indicator after about 10 seconds, so it is renewed.
+func (d *DiscordAdapter) keepTyping(ctx context.Context, s *discordgo.Session, channelID string) {
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.ChannelTyping(channelID); err != nil {
+			// Just warn, no need to stop the process
+			d.config.Logger.Warnw("Failed to set typing state", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// messageLength returns the configured message length, within Discord's limit.
+func (d *DiscordAdapter) messageLength() int {
+	if d.config.MaxMessageLength <= 0 || d.config.MaxMessageLength > maxMessageLength {
+		return maxMessageLength
+	}
+	return d.config.MaxMessageLength
 }
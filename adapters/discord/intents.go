@@ -0,0 +1,98 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+	"strings"
+)
+
+// Gateway close codes for intents the bot may not request.
+const (
+	closeInvalidIntents    = 4013
+	closeDisallowedIntents = 4014
+)
+
+var intentNames = map[string]discordgo.Intent{
+	"guilds":                   discordgo.IntentGuilds,
+	"guild_members":            discordgo.IntentGuildMembers,
+	"guild_moderation":         discordgo.IntentGuildModeration,
+	"guild_emojis":             discordgo.IntentGuildEmojis,
+	"guild_integrations":       discordgo.IntentGuildIntegrations,
+	"guild_webhooks":           discordgo.IntentGuildWebhooks,
+	"guild_invites":            discordgo.IntentGuildInvites,
+	"guild_voice_states":       discordgo.IntentGuildVoiceStates,
+	"guild_presences":          discordgo.IntentGuildPresences,
+	"guild_messages":           discordgo.IntentGuildMessages,
+	"guild_message_reactions":  discordgo.IntentGuildMessageReactions,
+	"guild_message_typing":     discordgo.IntentGuildMessageTyping,
+	"direct_messages":          discordgo.IntentDirectMessages,
+	"direct_message_reactions": discordgo.IntentDirectMessageReactions,
+	"direct_message_typing":    discordgo.IntentDirectMessageTyping,
+	"message_content":          discordgo.IntentMessageContent,
+	"guild_scheduled_events":   discordgo.IntentGuildScheduledEvents,
+}
+
+// privilegedIntents have to be enabled for the bot in the developer portal.
+var privilegedIntents = []string{"guild_members", "guild_presences", "message_content"}
+
+// defaultIntents are requested if none are configured.
+var defaultIntents = []string{"guilds", "guild_messages", "message_content"}
+
+// intents returns the gateway intents to request: the configured ones, or
+// the defaults, plus the ones enabled features need.
+func (d *DiscordAdapter) intents() (discordgo.Intent, error) {
+	names := d.config.Intents
+	if len(names) == 0 {
+		names = defaultIntents
+	}
+
+	intents := discordgo.IntentGuilds | discordgo.IntentGuildMessages | discordgo.IntentGuildMessageReactions
+	for _, name := range names {
+		intent, ok := intentNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown intent %q", name)
+		}
+		intents |= intent
+	}
+	if d.config.DirectMessages {
+		intents |= discordgo.IntentDirectMessages | discordgo.IntentDirectMessageReactions
+	}
+	return intents, nil
+}
+
+// closeCode returns the gateway close code of a connection error, or 0.
+func closeCode(err error) int {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return 0
+	}
+	return closeErr.Code
+}
+
+// intentsError explains a connection refused for the requested intents.
+func intentsError(err error, intents discordgo.Intent) error {
+	switch closeCode(err) {
+	case closeInvalidIntents:
+		return fmt.Errorf("discord refused the requested intents as invalid: %w", err)
+	case closeDisallowedIntents:
+		var privileged []string
+		for _, name := range privilegedIntents {
+			if intents&intentNames[name] != 0 {
+				privileged = append(privileged, name)
+			}
+		}
+		return fmt.Errorf("discord refused the privileged intents %s: enable them for the bot in the developer portal, "+
+			"or leave them out of intents (without message_content, only messages mentioning the bot are seen): %w",
+			strings.Join(privileged, ", "), err)
+	default:
+		return err
+	}
+}
+
+// contentVisible reports whether Discord sends the content of the message,
+// always the case with the message content intent.
+func (d *DiscordAdapter) contentVisible(s *discordgo.Session, m *discordgo.Message) bool {
+	return !d.mentionOnly || m.GuildID == "" || mentions(m, s.State.User.ID)
+}
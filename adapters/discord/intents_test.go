@@ -0,0 +1,21 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"testing"
+)
+
+func TestCloseCode(t *testing.T) {
+	refused := fmt.Errorf("open: %w", &websocket.CloseError{Code: closeDisallowedIntents})
+	if got := closeCode(refused); got != closeDisallowedIntents {
+		t.Errorf("closeCode = %d, want %d", got, closeDisallowedIntents)
+	}
+	if got := closeCode(errors.New("dial failed")); got != 0 {
+		t.Errorf("closeCode of a plain error = %d, want 0", got)
+	}
+	if got := closeCode(nil); got != 0 {
+		t.Errorf("closeCode(nil) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,197 @@
+package discord
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var markupRegex = regexp.MustCompile(`<(@!?|@&|#)(\d+)>|<a?:(\w+):\d+>|<t:(-?\d+)(?::([tTdDfFR]))?>`)
+
+// memberIndex maps the users seen in each guild to their display names, so
+// "@Name" in responses can be turned back into mentions. Direct messages are
+// indexed under an empty guild ID.
+type memberIndex struct {
+	mu     sync.Mutex
+	guilds map[string]map[string]string // Guild ID -> user ID -> display name
+}
+
+func newMemberIndex() *memberIndex {
+	return &memberIndex{guilds: make(map[string]map[string]string)}
+}
+
+func (idx *memberIndex) add(guildID, userID, name string) {
+	if name == "" {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.guilds[guildID] == nil {
+		idx.guilds[guildID] = make(map[string]string)
+	}
+	idx.guilds[guildID][userID] = name
+}
+
+func (idx *memberIndex) name(guildID, userID string) (string, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	name, ok := idx.guilds[guildID][userID]
+	return name, ok
+}
+
+// mentionNames replaces "@Name" of indexed guild members with their mention.
+// Longer names are replaced first, so "@Ann Marie" wins over "@Ann". Names
+// ending in a letter or digit must not continue into a longer word.
+func (idx *memberIndex) mentionNames(guildID, content string) string {
+	if !strings.Contains(content, "@") {
+		return content
+	}
+
+	idx.mu.Lock()
+	type member struct{ id, name string }
+	members := make([]member, 0, len(idx.guilds[guildID]))
+	for id, name := range idx.guilds[guildID] {
+		members = append(members, member{id, name})
+	}
+	idx.mu.Unlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		return len(members[i].name) > len(members[j].name)
+	})
+
+	var b strings.Builder
+	for {
+		at := strings.IndexByte(content, '@')
+		if at < 0 {
+			break
+		}
+		b.WriteString(content[:at])
+		content = content[at+1:]
+
+		mentioned := false
+		for _, m := range members {
+			if strings.HasPrefix(content, m.name) && !continuesWord(m.name, content[len(m.name):]) {
+				b.WriteString("<@" + m.id + ">")
+				content = content[len(m.name):]
+				mentioned = true
+				break
+			}
+		}
+		if !mentioned {
+			b.WriteByte('@')
+		}
+	}
+	b.WriteString(content)
+	return b.String()
+}
+
+// continuesWord reports whether the text after a name continues its last word.
+func continuesWord(name, rest string) bool {
+	last, _ := utf8.DecodeLastRuneInString(name)
+	next, size := utf8.DecodeRuneInString(rest)
+	return size > 0 && isWordRune(last) && isWordRune(next)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// displayName returns the name a user is shown with: the guild nickname,
+// then the global display name, then the username.
+func displayName(user *discordgo.User, member *discordgo.Member) string {
+	if member != nil && member.Nick != "" {
+		return member.Nick
+	}
+	if user.GlobalName != "" {
+		return user.GlobalName
+	}
+	return user.Username
+}
+
+// normalizeMarkup replaces the mentions, custom emoji and timestamps of a
+// message with readable text, e.g. "<@123>" with "@Name" and "<#456>" with
+// "#general". The bot's own mention becomes "@NeighBot".
+func (d *DiscordAdapter) normalizeMarkup(s *discordgo.Session, m *discordgo.Message) string {
+	for _, user := range m.Mentions {
+		var member *discordgo.Member
+		if user.ID == m.Author.ID {
+			member = m.Member
+		}
+		d.members.add(m.GuildID, user.ID, displayName(user, member))
+	}
+
+	return markupRegex.ReplaceAllStringFunc(m.Content, func(markup string) string {
+		match := markupRegex.FindStringSubmatch(markup)
+		switch {
+		case match[1] == "@" || match[1] == "@!":
+			if match[2] == s.State.User.ID {
+				return "@NeighBot"
+			}
+			return "@" + d.userName(s, m.GuildID, match[2])
+		case match[1] == "@&":
+			if role, err := s.State.Role(m.GuildID, match[2]); err == nil {
+				return "@" + role.Name
+			}
+			return "@unknown-role"
+		case match[1] == "#":
+			if channel, err := d.channel(s, match[2]); err == nil {
+				return "#" + channel.Name
+			}
+			return "#unknown-channel"
+		case match[3] != "":
+			return ":" + match[3] + ":"
+		default:
+			return formatTimestamp(match[4], match[5])
+		}
+	})
+}
+
+// userName resolves a user ID to a display name from the member index, the
+// state cache or the API, in that order.
+func (d *DiscordAdapter) userName(s *discordgo.Session, guildID, userID string) string {
+	if name, ok := d.members.name(guildID, userID); ok {
+		return name
+	}
+	if guildID == "" {
+		return "unknown-user"
+	}
+
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		// Try with GET
+		if member, err = s.GuildMember(guildID, userID); err != nil {
+			return "unknown-user"
+		}
+	}
+	name := displayName(member.User, member)
+	d.members.add(guildID, userID, name)
+	return name
+}
+
+// formatTimestamp renders a <t:unix:style> timestamp, relative styles are
+// rendered as absolute times.
+func formatTimestamp(unix, style string) string {
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return "<t:" + unix + ">"
+	}
+	t := time.Unix(seconds, 0).UTC()
+	switch style {
+	case "t":
+		return t.Format("15:04 MST")
+	case "T":
+		return t.Format("15:04:05 MST")
+	case "d":
+		return t.Format("2006-01-02")
+	case "D":
+		return t.Format("January 2, 2006")
+	default:
+		return t.Format("Monday, January 2, 2006 15:04 MST")
+	}
+}
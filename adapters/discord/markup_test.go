@@ -0,0 +1,35 @@
+package discord
+
+import "testing"
+
+func TestMentionNames(t *testing.T) {
+	idx := newMemberIndex()
+	idx.add("g", "1", "Ann")
+	idx.add("g", "2", "Ann Marie")
+	idx.add("g", "3", "José")
+	idx.add("g", "4", "Zoë")
+	idx.add("g", "5", "R2-D2!")
+	idx.add("other", "6", "Bob")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"hi @Ann", "hi <@1>"},
+		{"hi @Ann Marie!", "hi <@2>!"},
+		{"@Ann, @Ann", "<@1>, <@1>"},
+		{"@Annabel", "@Annabel"},
+		{"thanks @José.", "thanks <@3>."},
+		{"@Joséphine", "@Joséphine"},
+		{"@Zoë and @Zoëy", "<@4> and @Zoëy"},
+		{"@R2-D2!!", "<@5>!"},
+		{"@Bob", "@Bob"},
+		{"mail ann@example.com", "mail ann@example.com"},
+		{"no mentions", "no mentions"},
+	}
+	for _, tt := range tests {
+		if got := idx.mentionNames("g", tt.input); got != tt.want {
+			t.Errorf("mentionNames(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package discord
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"strings"
+)
+
+// reactionAddHandler records 👍 and 👎 reactions to the bot's responses as
+// feedback.
+func (d *DiscordAdapter) reactionAddHandler(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	rating := reactionRating(r.Emoji)
+	if rating == 0 || r.UserID == s.State.User.ID {
+		return
+	}
+	ctx := d.route(s, r.ChannelID)
+	if ctx == nil {
+		return
+	}
+	if err := d.pipeline.AddFeedback(ctx, r.MessageID, r.UserID, rating); err != nil {
+		d.config.Logger.Errorw("Failed to add feedback", "error", err)
+	}
+}
+
+func (d *DiscordAdapter) reactionRemoveHandler(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+	rating := reactionRating(r.Emoji)
+	if rating == 0 || r.UserID == s.State.User.ID {
+		return
+	}
+	ctx := d.route(s, r.ChannelID)
+	if ctx == nil {
+		return
+	}
+	if err := d.pipeline.RemoveFeedback(ctx, r.MessageID, r.UserID, rating); err != nil {
+		d.config.Logger.Errorw("Failed to remove feedback", "error", err)
+	}
+}
+
+// reactionRating returns 1 for 👍, -1 for 👎 in any skin tone, and 0 for
+// other emoji.
+func reactionRating(emoji discordgo.Emoji) int {
+	switch {
+	case emoji.ID != "":
+		// Custom emoji
+		return 0
+	case strings.HasPrefix(emoji.Name, "👍"):
+		return 1
+	case strings.HasPrefix(emoji.Name, "👎"):
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,473 @@
+package adapters
+
+import (
+	"NeighBot/filters"
+	"NeighBot/llm"
+	"NeighBot/metrics"
+	"NeighBot/triggers"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBusy is returned by Pipeline.Respond while another response is being generated.
+	ErrBusy = errors.New("already responding")
+	// ErrDropped is returned by Pipeline.Record when an input filter rejected the message.
+	ErrDropped = errors.New("message dropped")
+)
+
+// InboundMessage is a chat message received by an adapter, already
+// converted from the platform's format to plain text.
+type InboundMessage struct {
+	ChatID    string
+	MessageID string // Native message ID, needed to follow edits and deletes
+	ReplyToID string
+	Source    string
+	UserID    string
+	Username  string
+	Content   string
+	Images    []Attachment
+	Timestamp time.Time // When the message was sent, zero for now
+}
+
+// Attachment is a file attached to an inbound message.
+type Attachment struct {
+	URL      string
+	Filename string
+	MimeType string
+}
+
+// Pipeline is the platform-independent part of handling chat messages:
+// routing them to a context, storing them in memory and generating filtered
+// responses. Every adapter instance owns one.
+type Pipeline struct {
+	instanceName string
+	memoryStore  *llm.MemoryStore
+	llmClient    llm.Provider
+	metrics      *metrics.Metrics
+	log          *zap.SugaredLogger
+
+	mu         sync.Mutex
+	responding bool
+}
+
+func NewPipeline(cfg *ChatAdapterConfig) *Pipeline {
+	return &Pipeline{
+		instanceName: cfg.Name,
+		memoryStore:  cfg.MemoryStore,
+		llmClient:    cfg.LLMClient,
+		metrics:      cfg.Metrics,
+		log:          cfg.Logger,
+	}
+}
+
+// Route returns the context associated with the chat, or nil for unknown chats.
+func (p *Pipeline) Route(chatID string) *llm.StoredContext {
+	return p.memoryStore.GetContextForChat(p.instanceName, chatID)
+}
+
+// RouteDirect returns the context of a direct message chat, creating a
+// context for the user on their first message.
+func (p *Pipeline) RouteDirect(chatID, userID, username string) *llm.StoredContext {
+	if ctx := p.Route(chatID); ctx != nil {
+		return ctx
+	}
+
+	ctx := &llm.StoredContext{
+		ID:              fmt.Sprintf("dm-%s-%s", p.instanceName, userID),
+		Name:            "DM with " + username,
+		Description:     "Direct messages, created automatically.",
+		Filters:         llm.DefaultFilters(),
+		InputFilters:    filters.Specs{},
+		AssociatedChats: []string{p.instanceName + ":" + chatID},
+	}
+	p.memoryStore.AddContext(ctx)
+	p.log.Infow("Created context for direct messages",
+		"adapter", p.instanceName,
+		"context_id", ctx.ID,
+		"username", username,
+	)
+	return p.Route(chatID)
+}
+
+// RouteThread returns the context of a thread under a parent chat. Threads
+// share the parent's context, unless it asks for separate thread memory, in
+// which case a derived context is created, seeded with the parent's messages
+// with the seed IDs, e.g. the thread's starter message, if they were stored.
+func (p *Pipeline) RouteThread(threadID, parentChatID string, seedIDs ...string) *llm.StoredContext {
+	if ctx := p.Route(threadID); ctx != nil {
+		return ctx
+	}
+	parent := p.Route(parentChatID)
+	if parent == nil || !parent.HasThreadMemory() {
+		return parent
+	}
+
+	var seed []llm.StoredMessage
+	for _, messageID := range seedIDs {
+		if message, ok := parent.Message(messageID); ok {
+			seed = append(seed, message)
+		}
+	}
+	ctx := p.memoryStore.DeriveContext(parent,
+		fmt.Sprintf("%s-thread-%s", parent.ID, threadID),
+		parent.Name+" (thread)",
+		[]string{p.instanceName + ":" + threadID},
+		seed,
+	)
+	p.log.Infow("Created context for thread",
+		"adapter", p.instanceName,
+		"context_id", ctx.ID,
+		"parent", parent.ID,
+	)
+	return ctx
+}
+
+// LookupThread returns the context of a thread like RouteThread, without
+// creating one. It is nil for threads of a parent with separate thread memory
+// that have no context yet.
+func (p *Pipeline) LookupThread(threadID, parentChatID string) *llm.StoredContext {
+	if ctx := p.Route(threadID); ctx != nil {
+		return ctx
+	}
+	parent := p.Route(parentChatID)
+	if parent == nil || parent.HasThreadMemory() {
+		return nil
+	}
+	return parent
+}
+
+// ShouldRespond evaluates the context's trigger rules on a recorded message.
+func (p *Pipeline) ShouldRespond(ctx *llm.StoredContext, msg triggers.Message) bool {
+	reason, ok := ctx.MatchTrigger(msg)
+	if ok {
+		p.log.Debugw("Response triggered",
+			"adapter", p.instanceName,
+			"context_id", ctx.ID,
+			"trigger", reason,
+		)
+	}
+	return ok
+}
+
+// Record runs the context's input filters on an inbound message and stores
+// it in memory. Messages rejected by a filter are logged and ErrDropped is
+// returned.
+func (p *Pipeline) Record(ctx *llm.StoredContext, msg InboundMessage) error {
+	// Log the incoming message
+	p.log.Infow("Incoming message",
+		"adapter", p.instanceName,
+		"author", msg.Username,
+		"content", msg.Content,
+		"chat_id", msg.ChatID,
+	)
+
+	message, err := p.prepare(ctx, msg)
+	if err != nil {
+		return err
+	}
+	return p.memoryStore.AddUserMessage(ctx.ID, message)
+}
+
+// Backfill stores messages missed while NeighBot was away, like Record but
+// inserting them by timestamp and skipping the ones already stored. It
+// returns how many were stored.
+func (p *Pipeline) Backfill(ctx *llm.StoredContext, msgs []InboundMessage) (int, error) {
+	var messages []llm.StoredMessage
+	for _, msg := range msgs {
+		if _, ok := ctx.Message(msg.MessageID); ok {
+			continue
+		}
+		message, err := p.prepare(ctx, msg)
+		if errors.Is(err, ErrDropped) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		messages = append(messages, message)
+	}
+
+	inserted, err := p.memoryStore.InsertMessages(ctx.ID, messages)
+	if inserted > 0 {
+		p.log.Infow("Backfilled messages",
+			"adapter", p.instanceName,
+			"context_id", ctx.ID,
+			"count", inserted,
+		)
+	}
+	return inserted, err
+}
+
+// Chats returns the chats of the contexts this adapter instance handles.
+func (p *Pipeline) Chats() []string {
+	return p.memoryStore.ChatsForInstance(p.instanceName)
+}
+
+// prepare runs the input filters on an inbound message and caches its
+// images, converting it for storage.
+func (p *Pipeline) prepare(ctx *llm.StoredContext, msg InboundMessage) (llm.StoredMessage, error) {
+	content, err := ctx.ApplyInputFilters(msg.Content, filters.Meta{
+		Source:   msg.Source,
+		Username: msg.Username,
+	})
+	var rejected *filters.RejectError
+	if errors.As(err, &rejected) {
+		p.log.Infow("Dropped incoming message",
+			"adapter", p.instanceName,
+			"author", msg.Username,
+			"chat_id", msg.ChatID,
+			"filter", rejected.Filter,
+			"reason", rejected.Reason,
+		)
+		return llm.StoredMessage{}, fmt.Errorf("%w: %w", ErrDropped, err)
+	}
+	if err != nil {
+		return llm.StoredMessage{}, err
+	}
+
+	var parts []llm.ContentPart
+	for _, image := range msg.Images {
+		part, err := p.memoryStore.CacheImage(ctx.ID, msg.MessageID, image.URL, image.Filename, image.MimeType)
+		if err != nil {
+			p.log.Warnw("Failed to cache image", "adapter", p.instanceName, "filename", image.Filename, "error", err)
+		}
+		parts = append(parts, part)
+	}
+
+	return llm.StoredMessage{
+		MessageID: msg.MessageID,
+		ChatID:    msg.ChatID,
+		ReplyToID: msg.ReplyToID,
+		UserID:    msg.UserID,
+		Username:  msg.Username,
+		Source:    msg.Source,
+		Content:   content,
+		Parts:     parts,
+		Timestamp: msg.Timestamp,
+	}, nil
+}
+
+// Edit runs the input filters on the new content of an edited message and
+// updates it in memory. Edits rejected by a filter delete the message.
+// Messages that were never stored are ignored.
+func (p *Pipeline) Edit(ctx *llm.StoredContext, msg InboundMessage) error {
+	content, err := ctx.ApplyInputFilters(msg.Content, filters.Meta{
+		Source:   msg.Source,
+		Username: msg.Username,
+	})
+	var rejected *filters.RejectError
+	if errors.As(err, &rejected) {
+		p.log.Infow("Deleting message rejected after edit",
+			"adapter", p.instanceName,
+			"chat_id", msg.ChatID,
+			"message_id", msg.MessageID,
+			"filter", rejected.Filter,
+			"reason", rejected.Reason,
+		)
+		return p.Delete(ctx, msg.MessageID)
+	}
+	if err != nil {
+		return err
+	}
+
+	updated, err := p.memoryStore.UpdateMessage(ctx.ID, msg.MessageID, content)
+	if updated {
+		p.log.Infow("Edited message",
+			"adapter", p.instanceName,
+			"chat_id", msg.ChatID,
+			"message_id", msg.MessageID,
+			"content", content,
+		)
+	}
+	return err
+}
+
+// Delete tombstones deleted messages in memory.
+func (p *Pipeline) Delete(ctx *llm.StoredContext, messageIDs ...string) error {
+	deleted, err := p.memoryStore.DeleteMessages(ctx.ID, messageIDs...)
+	if deleted > 0 {
+		p.log.Infow("Deleted messages",
+			"adapter", p.instanceName,
+			"context_id", ctx.ID,
+			"count", deleted,
+		)
+	}
+	return err
+}
+
+// Respond generates a response from the context's memory, filters it and
+// stores it as an assistant message. Responses rejected or emptied by the
+// output filters are generated again with a nudge, up to the context's retry
+// limit, after which its fallback reply is used. onStart, if set, is called
+// once the pipeline commits to generating, e.g. to show a typing indicator.
+// Only one response is generated at a time, ErrBusy is returned otherwise.
+// If the model reacted instead of replying, the response is empty and the
+// emoji to react with is returned as the reaction.
+func (p *Pipeline) Respond(goCtx context.Context, ctx *llm.StoredContext, source string, onStart func()) (response, reaction string, err error) {
+	// If already responding, skip
+	p.mu.Lock()
+	if p.responding {
+		p.mu.Unlock()
+		return "", "", ErrBusy
+	}
+	p.responding = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.responding = false
+		p.mu.Unlock()
+	}()
+
+	if onStart != nil {
+		onStart()
+	}
+
+	response, reaction, err = p.generate(goCtx, ctx, source)
+	if err != nil {
+		p.metrics.Inc("responses.failed")
+		return "", "", err
+	}
+
+	// Reactions are remembered in the form the model uses for them
+	content := response
+	if reaction != "" {
+		content = "[react:" + reaction + "]"
+	}
+
+	// Log the response
+	p.log.Infow("Generated response",
+		"adapter", p.instanceName,
+		"content", content,
+		"context_id", ctx.ID,
+	)
+
+	// Add response
+	if err = p.memoryStore.AddAssistantMessage(ctx.ID, source, content); err != nil {
+		return "", "", err
+	}
+
+	return response, reaction, nil
+}
+
+// Sent records the native IDs of the messages the latest response was sent
+// as, so feedback on them can be recorded.
+func (p *Pipeline) Sent(ctx *llm.StoredContext, messageIDs ...string) error {
+	return p.memoryStore.SetMessageIDs(ctx.ID, messageIDs...)
+}
+
+// ReactionFailed replaces the latest response, a reaction that could not be
+// added, with the context's fallback reply, returned to be sent instead.
+func (p *Pipeline) ReactionFailed(ctx *llm.StoredContext) (string, error) {
+	p.metrics.Inc("responses.reaction_failed")
+	reply := ctx.RegenerationConfig().FallbackReply()
+	return reply, p.memoryStore.ReplaceResponse(ctx.ID, reply)
+}
+
+// AddFeedback records a user's rating of a response, 1 for good and -1 for
+// bad. Ratings of other messages are ignored.
+func (p *Pipeline) AddFeedback(ctx *llm.StoredContext, messageID, userID string, rating int) error {
+	found, err := p.memoryStore.AddFeedback(ctx.ID, messageID, userID, rating)
+	if found {
+		p.metrics.Inc(feedbackMetric(rating))
+		p.log.Infow("Feedback added",
+			"adapter", p.instanceName,
+			"context_id", ctx.ID,
+			"message_id", messageID,
+			"rating", rating,
+		)
+	}
+	return err
+}
+
+// RemoveFeedback removes a user's rating of a response.
+func (p *Pipeline) RemoveFeedback(ctx *llm.StoredContext, messageID, userID string, rating int) error {
+	_, err := p.memoryStore.RemoveFeedback(ctx.ID, messageID, userID, rating)
+	return err
+}
+
+func feedbackMetric(rating int) string {
+	if rating > 0 {
+		return "feedback.up"
+	}
+	return "feedback.down"
+}
+
+// generate returns the first response passing the output filters, or the
+// fallback reply once the retries are exhausted. Reactions are returned
+// separately, with an empty response.
+func (p *Pipeline) generate(goCtx context.Context, ctx *llm.StoredContext, source string) (string, string, error) {
+	regeneration := ctx.RegenerationConfig()
+	opts := ctx.GenerateOptions()
+	history := ctx.PromptMessages()
+	p.memoryStore.LoadImages(history)
+	// Reactions are parsed if the prompt asked for them, even if turned off meanwhile
+	instructions, reactions := ctx.ReactionsMessage()
+	if reactions {
+		// Right after the system prompt
+		i := 0
+		for i < len(history) && history[i].Role == "system" {
+			i++
+		}
+		history = slices.Insert(history, i, instructions)
+	}
+	messages := history
+	for attempt := 0; attempt <= regeneration.Retries(); attempt++ {
+		// Generate response from LLM
+		generated, err := p.llmClient.GenerateResponse(goCtx, messages, opts)
+		if err != nil {
+			return "", "", err
+		}
+
+		// Reactions are not text, the output filters do not apply
+		var response string
+		emoji, ok := llm.ParseReaction(generated)
+		if reactions && ok && emoji != "" {
+			p.metrics.Inc("responses.reaction")
+			return "", emoji, nil
+		} else if reactions && ok {
+			err = filters.Reject("", "reactions must be a single emoji")
+		} else {
+			// Apply filters to the response, an emptied response counts as rejected
+			response, err = ctx.ApplyFilters(generated, filters.Meta{Source: source, Username: "NeighBot"})
+			if err == nil && strings.TrimSpace(response) == "" {
+				err = filters.Reject("", "empty response")
+			}
+		}
+		var rejected *filters.RejectError
+		if !errors.As(err, &rejected) {
+			if err != nil {
+				return "", "", err
+			}
+			if attempt > 0 {
+				p.metrics.Inc("responses.regenerated")
+			} else {
+				p.metrics.Inc("responses.ok")
+			}
+			return response, "", nil
+		}
+
+		p.metrics.Inc("responses.rejected")
+		p.log.Infow("Response rejected by filters",
+			"adapter", p.instanceName,
+			"context_id", ctx.ID,
+			"attempt", attempt+1,
+			"filter", rejected.Filter,
+			"reason", rejected.Reason,
+		)
+		messages = append(slices.Clip(history), regeneration.NudgeMessage(rejected.Reason))
+	}
+
+	p.metrics.Inc("responses.fallback")
+	p.log.Warnw("Retries exhausted, sending fallback reply",
+		"adapter", p.instanceName,
+		"context_id", ctx.ID,
+	)
+	return regeneration.FallbackReply(), "", nil
+}
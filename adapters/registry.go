@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -11,9 +12,16 @@ type adapterEntry struct {
 	configType  reflect.Type
 }
 
-var adapterRegistry = make(map[string]adapterEntry)
+// Registry maps adapter type names to their implementation and config types.
+type Registry struct {
+	entries map[string]adapterEntry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]adapterEntry)}
+}
 
-func RegisterAdapter(name string, adapterPrototype ChatAdapter, configPrototype interface{}) error {
+func (r *Registry) RegisterAdapter(name string, adapterPrototype ChatAdapter, configPrototype interface{}) error {
 	adapterVal := reflect.ValueOf(adapterPrototype)
 	adapterType := adapterVal.Type()
 	if adapterType.Kind() != reflect.Ptr || adapterType.Elem().Kind() != reflect.Struct {
@@ -26,7 +34,7 @@ func RegisterAdapter(name string, adapterPrototype ChatAdapter, configPrototype
 		return errors.New("configPrototype must be a struct")
 	}
 
-	adapterRegistry[name] = adapterEntry{
+	r.entries[name] = adapterEntry{
 		adapterType: adapterType,
 		configType:  configType,
 	}
@@ -34,8 +42,8 @@ func RegisterAdapter(name string, adapterPrototype ChatAdapter, configPrototype
 	return nil
 }
 
-func CreateAdapter(name string) (ChatAdapter, reflect.Type, error) {
-	entry, ok := adapterRegistry[name]
+func (r *Registry) CreateAdapter(name string) (ChatAdapter, reflect.Type, error) {
+	entry, ok := r.entries[name]
 	if !ok {
 		return nil, nil, fmt.Errorf("adapter not found: %s", name)
 	}
@@ -49,28 +57,87 @@ func CreateAdapter(name string) (ChatAdapter, reflect.Type, error) {
 	return adapter, entry.configType, nil
 }
 
-func RegisteredAdapters() []string {
-	keys := make([]string, 0, len(adapterRegistry))
-	for k := range adapterRegistry {
+func (r *Registry) RegisteredAdapters() []string {
+	keys := make([]string, 0, len(r.entries))
+	for k := range r.entries {
 		keys = append(keys, k)
 	}
 	return keys
 }
 
-func ConfigTypeForAdapter(name string) reflect.Type {
-	entry, ok := adapterRegistry[name]
+func (r *Registry) ConfigTypeForAdapter(name string) reflect.Type {
+	entry, ok := r.entries[name]
 	if !ok {
 		return nil
 	}
 	return entry.configType
 }
 
-func NewAdapterDefaultConfig(adapterName string) interface{} {
-	configType := ConfigTypeForAdapter(adapterName)
+func (r *Registry) NewAdapterDefaultConfig(adapterName string) interface{} {
+	configType := r.ConfigTypeForAdapter(adapterName)
 	if configType == nil {
 		return nil
 	}
 
 	defaultConfig := reflect.New(configType).Interface()
+	if baseConfig := BaseConfig(defaultConfig); baseConfig != nil {
+		baseConfig.Type = adapterName
+	}
 	return defaultConfig
 }
+
+// InstanceType returns the adapter type of a raw instance config.
+// Configs without a type use the instance name, as they did before
+// adapters could have several instances.
+func InstanceType(instanceName string, data interface{}) string {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if raw, err := json.Marshal(data); err == nil {
+		_ = json.Unmarshal(raw, &typed)
+	}
+	if typed.Type == "" {
+		return instanceName
+	}
+	return typed.Type
+}
+
+// EnsureDefaultConfigs adds a default instance for every registered adapter
+// type that has no configured instance yet.
+func (r *Registry) EnsureDefaultConfigs(configs map[string]interface{}) {
+	configured := make(map[string]bool)
+	for instanceName, data := range configs {
+		configured[InstanceType(instanceName, data)] = true
+	}
+
+	for _, adapterName := range r.RegisteredAdapters() {
+		if configured[adapterName] {
+			continue
+		}
+		if _, exists := configs[adapterName]; exists {
+			continue
+		}
+		configs[adapterName] = r.NewAdapterDefaultConfig(adapterName)
+	}
+}
+
+// BaseConfig returns the ChatAdapterConfig embedded in an adapter config.
+func BaseConfig(adapterConfig interface{}) *ChatAdapterConfig {
+	v := reflect.ValueOf(adapterConfig)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// Iterate through fields to find embedded ChatAdapterConfig
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Type() == reflect.TypeOf(ChatAdapterConfig{}) {
+			return field.Addr().Interface().(*ChatAdapterConfig)
+		}
+	}
+
+	return nil
+}
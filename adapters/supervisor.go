@@ -0,0 +1,231 @@
+package adapters
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type AdapterState int
+
+const (
+	StateStopped AdapterState = iota
+	StateStarting
+	StateRunning
+	StateFailed
+)
+
+func (s AdapterState) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Backoff describes the delays between restart attempts of a failed adapter.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+}
+
+var DefaultBackoff = Backoff{
+	Initial: time.Second,
+	Max:     5 * time.Minute,
+	Factor:  2,
+}
+
+// Delay returns the delay before the given attempt, starting from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < attempt; i++ {
+		delay = time.Duration(float64(delay) * b.Factor)
+		if delay >= b.Max {
+			return b.Max
+		}
+	}
+	return delay
+}
+
+// AdapterStatus is a snapshot of a supervised adapter.
+type AdapterStatus struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Supervisor tracks the state of an initialized adapter and keeps
+// restarting it with exponential backoff while starting fails, or when it
+// fails after starting.
+type Supervisor struct {
+	name    string
+	adapter ChatAdapter
+	backoff Backoff
+	log     *zap.SugaredLogger
+
+	mu       sync.Mutex
+	state    AdapterState
+	attempts int
+	lastErr  error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewSupervisor(name string, adapter ChatAdapter, backoff Backoff, log *zap.SugaredLogger) *Supervisor {
+	return &Supervisor{
+		name:    name,
+		adapter: adapter,
+		backoff: backoff,
+		log:     log,
+	}
+}
+
+// Start starts the adapter in the background. Failed attempts and failures
+// of the running adapter are retried until Stop is called. Starting a
+// running adapter is a no-op.
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.state = StateStarting
+	s.attempts = 0
+	s.lastErr = nil
+	go s.run(s.stop, s.done)
+}
+
+func (s *Supervisor) run(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		s.mu.Lock()
+		s.attempts++
+		attempt := s.attempts
+		s.state = StateStarting
+		s.mu.Unlock()
+
+		err := s.adapter.Start()
+		if err == nil {
+			started := time.Now()
+			s.mu.Lock()
+			s.state = StateRunning
+			s.lastErr = nil
+			s.mu.Unlock()
+			s.log.Infow("Adapter started", "adapter", s.name, "attempt", attempt)
+
+			select {
+			case <-stop:
+				return
+			case err = <-s.adapter.Failed():
+			}
+			if err == nil {
+				err = errors.New("adapter stopped unexpectedly")
+			}
+			if stopErr := s.adapter.Stop(); stopErr != nil {
+				s.log.Warnw("Failed to stop failed adapter", "adapter", s.name, "error", stopErr)
+			}
+
+			// Adapters that ran for a while start over with the initial delay
+			if time.Since(started) >= s.backoff.Max {
+				s.mu.Lock()
+				s.attempts, attempt = 1, 1
+				s.mu.Unlock()
+			}
+		}
+
+		s.mu.Lock()
+		s.state = StateFailed
+		s.lastErr = err
+		s.mu.Unlock()
+
+		delay := s.backoff.Delay(attempt)
+		s.log.Errorw("Adapter failed, restarting",
+			"adapter", s.name,
+			"attempt", attempt,
+			"retry_in", delay,
+			"error", err,
+		)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Stop cancels pending restarts and stops the adapter if it is running.
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wasRunning := s.state == StateRunning
+	s.state = StateStopped
+	if !wasRunning {
+		return nil
+	}
+
+	s.log.Infow("Stopping adapter", "adapter", s.name)
+	if err := s.adapter.Stop(); err != nil {
+		s.lastErr = err
+		return err
+	}
+	s.log.Infow("Adapter stopped successfully", "adapter", s.name)
+	return nil
+}
+
+func (s *Supervisor) Restart() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	s.Start()
+	return nil
+}
+
+func (s *Supervisor) Adapter() ChatAdapter {
+	return s.adapter
+}
+
+func (s *Supervisor) State() AdapterState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *Supervisor) Status() AdapterStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := AdapterStatus{
+		Name:     s.name,
+		State:    s.state.String(),
+		Attempts: s.attempts,
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+	return status
+}
@@ -0,0 +1,102 @@
+package adapters
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyAdapter fails to start a number of times, and can fail while running.
+type flakyAdapter struct {
+	mu         sync.Mutex
+	startFails int
+	starts     int
+	stops      int
+	failed     chan error
+}
+
+func (a *flakyAdapter) SetConfig(interface{}) error { return nil }
+func (a *flakyAdapter) Initialize() error           { return nil }
+func (a *flakyAdapter) Identity() AdapterIdentity   { return AdapterIdentity{Name: "flaky"} }
+
+func (a *flakyAdapter) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.starts++
+	if a.startFails > 0 {
+		a.startFails--
+		return errors.New("cannot connect")
+	}
+	a.failed = make(chan error, 1)
+	return nil
+}
+
+func (a *flakyAdapter) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stops++
+	return nil
+}
+
+func (a *flakyAdapter) Failed() <-chan error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.failed
+}
+
+func (a *flakyAdapter) disconnect() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failed <- errors.New("connection lost")
+}
+
+func (a *flakyAdapter) counts() (int, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.starts, a.stops
+}
+
+func waitState(t *testing.T, s *Supervisor, want AdapterState) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", s.State(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorRestarts(t *testing.T) {
+	adapter := &flakyAdapter{startFails: 2}
+	backoff := Backoff{Initial: time.Millisecond, Max: time.Hour, Factor: 2}
+	s := NewSupervisor("flaky", adapter, backoff, zap.NewNop().Sugar())
+
+	// Failed starts are retried
+	s.Start()
+	waitState(t, s, StateRunning)
+	if starts, _ := adapter.counts(); starts != 3 {
+		t.Errorf("got %d starts, want 3", starts)
+	}
+
+	// Running adapters that fail are stopped and started again
+	adapter.disconnect()
+	deadline := time.Now().Add(time.Second)
+	for starts, _ := adapter.counts(); starts < 4 && time.Now().Before(deadline); starts, _ = adapter.counts() {
+		time.Sleep(time.Millisecond)
+	}
+	waitState(t, s, StateRunning)
+	starts, stops := adapter.counts()
+	if starts != 4 || stops != 1 {
+		t.Errorf("got %d starts and %d stops, want 4 and 1", starts, stops)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, stops = adapter.counts(); stops != 2 {
+		t.Errorf("got %d stops, want 2", stops)
+	}
+}
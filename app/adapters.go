@@ -0,0 +1,240 @@
+package app
+
+import (
+	"NeighBot/adapters"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type supervisedAdapter struct {
+	supervisor *adapters.Supervisor // Nil for disabled adapters
+	config     []byte               // Normalized config the adapter was created with
+}
+
+func (a *App) handleAdapters() error {
+	a.supervisedMu.Lock()
+	defer a.supervisedMu.Unlock()
+	for instanceName, adapterConfigData := range a.config.Adapters.Configs {
+		normalized, err := a.normalizeAdapterConfig(instanceName, adapterConfigData)
+		if err != nil {
+			return err
+		}
+		if err = a.startAdapter(instanceName, normalized); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reloadAdapters restarts only the adapters whose config differs from the one
+// they were started with. It returns the names of the restarted adapters.
+func (a *App) reloadAdapters() []string {
+	a.supervisedMu.Lock()
+	defer a.supervisedMu.Unlock()
+
+	var changed []string
+
+	names := make(map[string]struct{})
+	for instanceName := range a.config.Adapters.Configs {
+		names[instanceName] = struct{}{}
+	}
+	for instanceName := range a.supervisedAdapters {
+		names[instanceName] = struct{}{}
+	}
+
+	for instanceName := range names {
+		var normalized []byte
+		if data, exists := a.config.Adapters.Configs[instanceName]; exists {
+			var err error
+			normalized, err = a.normalizeAdapterConfig(instanceName, data)
+			if err != nil {
+				a.log.Errorw("Invalid adapter config, keeping previous one", "adapter", instanceName, "error", err)
+				continue
+			}
+		}
+
+		supervised, exists := a.supervisedAdapters[instanceName]
+		if exists && bytes.Equal(supervised.config, normalized) {
+			continue
+		}
+
+		changed = append(changed, instanceName)
+		a.stopAdapter(instanceName)
+		if normalized == nil {
+			continue
+		}
+		if err := a.startAdapter(instanceName, normalized); err != nil {
+			a.log.Errorw("Failed to restart adapter", "adapter", instanceName, "error", err)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+func (a *App) stopAdapters() {
+	a.supervisedMu.Lock()
+	defer a.supervisedMu.Unlock()
+	for instanceName := range a.supervisedAdapters {
+		a.stopAdapter(instanceName)
+	}
+}
+
+// StartAdapter starts a stopped adapter at runtime.
+func (a *App) StartAdapter(instanceName string) error {
+	supervisor, err := a.lookupSupervisor(instanceName)
+	if err != nil {
+		return err
+	}
+	supervisor.Start()
+	return nil
+}
+
+// StopAdapter stops a running adapter at runtime, keeping its config.
+func (a *App) StopAdapter(instanceName string) error {
+	supervisor, err := a.lookupSupervisor(instanceName)
+	if err != nil {
+		return err
+	}
+	return supervisor.Stop()
+}
+
+// RestartAdapter stops and starts an adapter at runtime.
+func (a *App) RestartAdapter(instanceName string) error {
+	supervisor, err := a.lookupSupervisor(instanceName)
+	if err != nil {
+		return err
+	}
+	return supervisor.Restart()
+}
+
+// Adapter returns the adapter of an enabled instance.
+func (a *App) Adapter(instanceName string) (adapters.ChatAdapter, error) {
+	supervisor, err := a.lookupSupervisor(instanceName)
+	if err != nil {
+		return nil, err
+	}
+	return supervisor.Adapter(), nil
+}
+
+// AdapterStatuses returns the status of every configured adapter.
+func (a *App) AdapterStatuses() []adapters.AdapterStatus {
+	a.supervisedMu.Lock()
+	defer a.supervisedMu.Unlock()
+
+	statuses := make([]adapters.AdapterStatus, 0, len(a.supervisedAdapters))
+	for instanceName, supervised := range a.supervisedAdapters {
+		if supervised.supervisor == nil {
+			statuses = append(statuses, adapters.AdapterStatus{Name: instanceName, State: "disabled"})
+			continue
+		}
+		statuses = append(statuses, supervised.supervisor.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (a *App) lookupSupervisor(instanceName string) (*adapters.Supervisor, error) {
+	a.supervisedMu.Lock()
+	defer a.supervisedMu.Unlock()
+
+	supervised, exists := a.supervisedAdapters[instanceName]
+	if !exists {
+		return nil, fmt.Errorf("adapter not found: %s", instanceName)
+	}
+	if supervised.supervisor == nil {
+		return nil, fmt.Errorf("adapter disabled: %s", instanceName)
+	}
+	return supervised.supervisor, nil
+}
+
+// startAdapter creates an adapter instance from its normalized config and starts it
+// under a supervisor. Disabled adapters are recorded without being started,
+// so config changes enabling them later are detected.
+// Callers must hold a.supervisedMu.
+func (a *App) startAdapter(instanceName string, normalized []byte) error {
+	adapterType := adapters.InstanceType(instanceName, json.RawMessage(normalized))
+	adapter, configType, err := a.adapterRegistry.CreateAdapter(adapterType)
+	if err != nil {
+		return fmt.Errorf("create adapter %s: %w", instanceName, err)
+	}
+
+	adapterConfig := reflect.New(configType).Interface()
+	if err = json.Unmarshal(normalized, adapterConfig); err != nil {
+		return fmt.Errorf("unmarshal adapter config %s: %w", instanceName, err)
+	}
+
+	// Set shared dependencies
+	baseConfig := adapters.BaseConfig(adapterConfig)
+	if baseConfig == nil || !baseConfig.Enabled {
+		a.log.Infow("Adapter disabled", "adapter", instanceName, "type", adapterType)
+		a.supervisedAdapters[instanceName] = &supervisedAdapter{config: normalized}
+		return nil
+	}
+	baseConfig.Name = instanceName
+	baseConfig.MemoryStore = a.memoryStore
+	baseConfig.LLMClient = a.llmProvider
+	baseConfig.Logger = a.log
+	baseConfig.Metrics = a.metrics
+
+	// Pass the config to the adapter
+	if err = adapter.SetConfig(adapterConfig); err != nil {
+		return fmt.Errorf("set config for adapter %s: %w", instanceName, err)
+	}
+
+	// Initialize the adapter, starting is retried by the supervisor
+	if err = adapter.Initialize(); err != nil {
+		return fmt.Errorf("initialize adapter %s: %w", instanceName, err)
+	}
+
+	supervisor := adapters.NewSupervisor(instanceName, adapter, adapters.DefaultBackoff, a.log)
+	a.supervisedAdapters[instanceName] = &supervisedAdapter{supervisor: supervisor, config: normalized}
+	supervisor.Start()
+	return nil
+}
+
+// stopAdapter stops an adapter and forgets it. Callers must hold a.supervisedMu.
+func (a *App) stopAdapter(instanceName string) {
+	supervised, exists := a.supervisedAdapters[instanceName]
+	if !exists {
+		return
+	}
+	delete(a.supervisedAdapters, instanceName)
+	if supervised.supervisor == nil {
+		return
+	}
+
+	if err := supervised.supervisor.Stop(); err != nil {
+		a.log.Errorw("Failed to stop adapter", "adapter", instanceName, "error", err)
+	}
+}
+
+// normalizeAdapterConfig round-trips the raw config through the config type
+// of the instance's adapter, so configs can be compared regardless of how
+// they were loaded.
+func (a *App) normalizeAdapterConfig(instanceName string, data interface{}) ([]byte, error) {
+	adapterType := adapters.InstanceType(instanceName, data)
+	configType := a.adapterRegistry.ConfigTypeForAdapter(adapterType)
+	if configType == nil {
+		return nil, fmt.Errorf("adapter type not found for %s: %s", instanceName, adapterType)
+	}
+
+	configVal, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal adapter config %s: %w", instanceName, err)
+	}
+
+	adapterConfig := reflect.New(configType).Interface()
+	if err = json.Unmarshal(configVal, adapterConfig); err != nil {
+		return nil, fmt.Errorf("unmarshal adapter config %s: %w", instanceName, err)
+	}
+	if baseConfig := adapters.BaseConfig(adapterConfig); baseConfig != nil {
+		baseConfig.Type = adapterType
+	}
+
+	return json.Marshal(adapterConfig)
+}
@@ -0,0 +1,309 @@
+package app
+
+import (
+	"NeighBot/adapters"
+	"NeighBot/adapters/discord"
+	"NeighBot/config"
+	"NeighBot/filters"
+	"NeighBot/llm"
+	"NeighBot/logger"
+	"NeighBot/metrics"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// App owns everything a running NeighBot needs: config, memory, LLM provider,
+// filters and adapters. Several apps can run side by side in one process.
+type App struct {
+	configDir      string
+	mainConfigFile string
+	config         *config.MainConfig
+
+	log              *zap.SugaredLogger
+	memoryStore      *llm.MemoryStore
+	llmProvider      llm.Provider
+	metrics          *metrics.Metrics
+	filterRegistry   *filters.Registry
+	adapterRegistry  *adapters.Registry
+	adapterTypes     []adapterType
+	disableReloading bool
+
+	supervisedMu       sync.Mutex
+	supervisedAdapters map[string]*supervisedAdapter
+	watcher            *config.Watcher
+}
+
+type adapterType struct {
+	name            string
+	adapter         adapters.ChatAdapter
+	configPrototype interface{}
+}
+
+// Option configures an App.
+type Option func(*App)
+
+// WithConfigDir sets the directory holding main.json and the context data.
+func WithConfigDir(configDir string) Option {
+	return func(a *App) {
+		a.configDir = configDir
+	}
+}
+
+// WithLogger sets the logger, logging is discarded by default.
+func WithLogger(log *zap.SugaredLogger) Option {
+	return func(a *App) {
+		a.log = log
+	}
+}
+
+// WithLLMProvider replaces the OpenAI client created from the main config.
+func WithLLMProvider(provider llm.Provider) Option {
+	return func(a *App) {
+		a.llmProvider = provider
+	}
+}
+
+// WithMemoryStore replaces the memory store created in the data directory.
+func WithMemoryStore(memoryStore *llm.MemoryStore) Option {
+	return func(a *App) {
+		a.memoryStore = memoryStore
+	}
+}
+
+// WithFilterRegistry replaces the registry holding the built-in filters.
+func WithFilterRegistry(filterRegistry *filters.Registry) Option {
+	return func(a *App) {
+		a.filterRegistry = filterRegistry
+	}
+}
+
+// WithAdapterType registers an additional adapter type next to the built-in ones.
+func WithAdapterType(name string, adapterPrototype adapters.ChatAdapter, configPrototype interface{}) Option {
+	return func(a *App) {
+		a.adapterTypes = append(a.adapterTypes, adapterType{
+			name:            name,
+			adapter:         adapterPrototype,
+			configPrototype: configPrototype,
+		})
+	}
+}
+
+// WithoutConfigReload disables watching the config files for changes.
+func WithoutConfigReload() Option {
+	return func(a *App) {
+		a.disableReloading = true
+	}
+}
+
+// New loads the config and memory from the config directory and prepares
+// the adapters without starting them.
+func New(opts ...Option) (*App, error) {
+	a := &App{
+		metrics:            metrics.New(),
+		supervisedAdapters: make(map[string]*supervisedAdapter),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.configDir == "" {
+		return nil, errors.New("no config directory specified")
+	}
+	if a.log == nil {
+		a.log = logger.Nop()
+	}
+
+	// Ensure the directories exist
+	if err := os.MkdirAll(a.configDir, 0755); err != nil {
+		return nil, fmt.Errorf("create config directory: %w", err)
+	}
+
+	// Register adapters before loading the config, so missing adapter
+	// configs are filled with defaults
+	a.adapterRegistry = adapters.NewRegistry()
+	/* Adapter register list */
+	if err := a.adapterRegistry.RegisterAdapter("discord", &discord.DiscordAdapter{}, discord.DiscordConfig{}); err != nil {
+		return nil, err
+	}
+	/* End of adapter register list */
+	for _, t := range a.adapterTypes {
+		if err := a.adapterRegistry.RegisterAdapter(t.name, t.adapter, t.configPrototype); err != nil {
+			return nil, fmt.Errorf("register adapter %s: %w", t.name, err)
+		}
+	}
+
+	if err := a.loadMainConfig(); err != nil {
+		return nil, err
+	}
+
+	// Initialize filters
+	if a.filterRegistry == nil {
+		a.filterRegistry = filters.NewRegistry(a.log)
+		a.filterRegistry.SetBaseDir(a.configDir)
+		a.filterRegistry.RegisterDefaults()
+	}
+	if err := a.registerFilterPlugins(a.config.Filters.Plugins); err != nil {
+		return nil, fmt.Errorf("register filter plugins: %w", err)
+	}
+
+	if a.memoryStore == nil {
+		if err := a.loadMemoryStore(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize the LLM client
+	if a.llmProvider == nil {
+		a.llmProvider = llm.NewOpenAIClient(
+			a.config.LLM.APIKey,
+			a.config.LLM.Endpoint,
+			a.config.LLM.Model,
+			a.config.LLM.Vision,
+		)
+		a.log.Infow("LLM client initialized",
+			"endpoint", a.config.LLM.Endpoint,
+			"model", a.config.LLM.Model,
+			"vision", a.config.LLM.Vision,
+		)
+	}
+
+	return a, nil
+}
+
+func (a *App) loadMainConfig() error {
+	a.mainConfigFile = filepath.Join(a.configDir, "main.json")
+	a.config = &config.MainConfig{}
+
+	if _, err := os.Stat(a.mainConfigFile); os.IsNotExist(err) {
+		if err = a.config.CreateDefault(a.mainConfigFile); err != nil {
+			return fmt.Errorf("create default config: %w", err)
+		}
+		a.log.Infow("Created default config", "file", a.mainConfigFile)
+	} else if err = a.config.Load(a.mainConfigFile); err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	a.adapterRegistry.EnsureDefaultConfigs(a.config.Adapters.Configs)
+	return nil
+}
+
+// registerFilterPlugins registers the external filters declared in the main
+// config and unregisters the ones no longer declared. Nothing changes if any
+// of them is invalid.
+func (a *App) registerFilterPlugins(plugins map[string]filters.ExecConfig) error {
+	for name, plugin := range plugins {
+		if err := a.filterRegistry.ValidateExec(name, plugin); err != nil {
+			return err
+		}
+	}
+	for _, name := range a.filterRegistry.Plugins() {
+		if _, ok := plugins[name]; !ok {
+			a.filterRegistry.UnregisterExec(name)
+		}
+	}
+	for name, plugin := range plugins {
+		if err := a.filterRegistry.RegisterExec(name, plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) loadMemoryStore() error {
+	// Ensure the data directory exists
+	dataDir := filepath.Join(a.configDir, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("create data directory: %w", err)
+	}
+
+	// Initialize the memory store
+	a.memoryStore = llm.NewMemoryStore(dataDir, a.filterRegistry, a.log)
+	if err := a.memoryStore.LoadAllContexts(); err != nil {
+		return fmt.Errorf("load contexts: %w", err)
+	}
+	if err := a.memoryStore.PopulateEmptyFolders(); err != nil {
+		return fmt.Errorf("populate empty folders: %w", err)
+	}
+	return nil
+}
+
+// Start starts the adapters and the config watcher.
+func (a *App) Start() error {
+	if err := a.handleAdapters(); err != nil {
+		return fmt.Errorf("initialize adapters: %w", err)
+	}
+
+	if !a.disableReloading {
+		a.watcher = a.startConfigWatcher()
+	}
+	return nil
+}
+
+// Stop stops the adapters and saves the contexts and the main config.
+func (a *App) Stop() error {
+	a.log.Info("Stopping NeighBot")
+
+	// Pick up pending config edits so saving does not overwrite them
+	if a.watcher != nil {
+		a.watcher.Stop()
+		a.watcher.Poll()
+		a.watcher = nil
+	}
+
+	a.stopAdapters()
+
+	var errs []error
+	a.log.Info("Saving contexts")
+	if err := a.memoryStore.SaveAllContexts(); err != nil {
+		errs = append(errs, fmt.Errorf("save contexts: %w", err))
+	}
+
+	// Edits not applied, e.g. unparsable or with reloading disabled, are kept
+	if changed, err := a.config.ChangedOnDisk(a.mainConfigFile); err != nil {
+		errs = append(errs, fmt.Errorf("check config: %w", err))
+	} else if changed {
+		a.log.Warnw("Config file changed since it was applied, not saving", "file", a.mainConfigFile)
+	} else {
+		a.log.Info("Saving config")
+		if err = a.config.Save(a.mainConfigFile); err != nil {
+			errs = append(errs, fmt.Errorf("save config: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *App) Config() *config.MainConfig {
+	return a.config
+}
+
+func (a *App) MemoryStore() *llm.MemoryStore {
+	return a.memoryStore
+}
+
+func (a *App) LLMProvider() llm.Provider {
+	return a.llmProvider
+}
+
+func (a *App) Metrics() *metrics.Metrics {
+	return a.metrics
+}
+
+// ExportFeedback writes the responses users rated as JSON lines, see
+// llm.FeedbackRecord.
+func (a *App) ExportFeedback(w io.Writer) error {
+	return a.memoryStore.ExportFeedback(w)
+}
+
+func (a *App) FilterRegistry() *filters.Registry {
+	return a.filterRegistry
+}
+
+func (a *App) Logger() *zap.SugaredLogger {
+	return a.log
+}
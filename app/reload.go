@@ -0,0 +1,94 @@
+package app
+
+import (
+	"NeighBot/config"
+	"NeighBot/llm"
+	"fmt"
+	"path/filepath"
+)
+
+// startConfigWatcher polls the main config and all context configs for
+// changes and applies them without restarting. It returns nil if reloading
+// is disabled in the config.
+func (a *App) startConfigWatcher() *config.Watcher {
+	interval := a.config.ReloadEvery()
+	if interval == 0 {
+		a.log.Info("Config reloading disabled")
+		return nil
+	}
+
+	watcher := config.NewWatcher(interval)
+	watcher.Watch(a.mainConfigFile, a.reloadMainConfig)
+	watcher.Watch(a.memoryStore.ContextConfigPattern(), a.reloadContextConfig)
+	watcher.Start()
+
+	a.log.Infow("Config watcher started", "interval", interval)
+	return watcher
+}
+
+func (a *App) reloadMainConfig(path string) {
+	var fresh config.MainConfig
+	if err := fresh.Load(path); err != nil {
+		a.log.Errorw("Failed to reload config, keeping previous one", "file", path, "error", err)
+		return
+	}
+
+	a.adapterRegistry.EnsureDefaultConfigs(fresh.Adapters.Configs)
+
+	cfg := a.config
+	diff := cfg.Diff(&fresh)
+	if cfg.LLM != fresh.LLM {
+		if updater, ok := a.llmProvider.(llm.SettingsUpdater); ok {
+			updater.UpdateSettings(fresh.LLM.APIKey, fresh.LLM.Endpoint, fresh.LLM.Model, fresh.LLM.Vision)
+		} else {
+			a.log.Warnw("LLM provider does not support changing settings", "file", path)
+		}
+	}
+	if cfg.ReloadInterval != fresh.ReloadInterval {
+		a.log.Warnw("Reload interval change takes effect after restart", "file", path)
+	}
+
+	// The file counts as applied unless part of it was refused
+	applied := true
+	if len(cfg.ChangedPlugins(&fresh)) > 0 {
+		if err := a.registerFilterPlugins(fresh.Filters.Plugins); err != nil {
+			a.log.Errorw("Failed to reload filter plugins, keeping previous ones", "file", path, "error", err)
+			fresh.Filters = cfg.Filters
+			applied = false
+		} else if err = a.memoryStore.RebuildFilters(); err != nil {
+			a.log.Errorw("Failed to rebuild context filters", "file", path, "error", err)
+		}
+	}
+
+	cfg.LLM = fresh.LLM
+	cfg.Filters = fresh.Filters
+	cfg.ReloadInterval = fresh.ReloadInterval
+	cfg.Adapters = fresh.Adapters
+	if applied {
+		cfg.Adopt(&fresh)
+	}
+	for _, adapterName := range a.reloadAdapters() {
+		diff = append(diff, fmt.Sprintf("adapters.%s: restarted", adapterName))
+	}
+
+	if len(diff) == 0 {
+		a.log.Debugw("Config file touched without changes", "file", path)
+		return
+	}
+	a.log.Infow("Reloaded config", "file", path, "changes", diff)
+}
+
+func (a *App) reloadContextConfig(path string) {
+	contextID := filepath.Base(filepath.Dir(path))
+	diff, err := a.memoryStore.ReloadContextConfig(contextID)
+	if err != nil {
+		a.log.Errorw("Failed to reload context config, keeping previous one", "context_id", contextID, "error", err)
+		return
+	}
+
+	if len(diff) == 0 {
+		a.log.Debugw("Context config file touched without changes", "context_id", contextID)
+		return
+	}
+	a.log.Infow("Reloaded context config", "context_id", contextID, "changes", diff)
+}
@@ -1,24 +1,40 @@
 package config
 
 import (
-	"NeighBot/adapters"
+	"NeighBot/filters"
+	"NeighBot/utilities"
 	"encoding/json"
+	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"time"
 )
 
+const DefaultReloadInterval = 5 * time.Second
+
 type MainConfig struct {
-	Adapters AdaptersConfig `json:"adapters"`
-	LLM      LLMConfig      `json:"llm"`
+	Adapters       AdaptersConfig `json:"adapters"`
+	LLM            LLMConfig      `json:"llm"`
+	Filters        FiltersConfig  `json:"filters"`
+	ReloadInterval int            `json:"reload_interval"` // Seconds between config change checks, 0 for default, negative disables
+
+	digest string // Of the file content last loaded or saved
 }
 
 type AdaptersConfig struct {
-	Configs map[string]interface{} `json:"configs"`
+	Configs map[string]interface{} `json:"configs"` // Adapter instances keyed by instance name
+}
+
+type FiltersConfig struct {
+	Plugins map[string]filters.ExecConfig `json:"plugins"` // External filters keyed by filter name
 }
 
 type LLMConfig struct {
 	APIKey   string `json:"api_key"`
 	Endpoint string `json:"endpoint"`
 	Model    string `json:"model"`
+	Vision   bool   `json:"vision"` // Whether the model accepts images
 }
 
 func (cfg *MainConfig) Load(configPath string) error {
@@ -30,26 +46,98 @@ func (cfg *MainConfig) Load(configPath string) error {
 	if err = json.Unmarshal(data, cfg); err != nil {
 		return err
 	}
+	cfg.digest = utilities.Digest(data)
 
 	if cfg.Adapters.Configs == nil {
 		cfg.Adapters.Configs = make(map[string]interface{})
 	}
-	for _, adapterName := range adapters.RegisteredAdapters() {
-		if _, exists := cfg.Adapters.Configs[adapterName]; !exists {
-			cfg.Adapters.Configs[adapterName] = adapters.NewAdapterDefaultConfig(adapterName)
-		}
-	}
 
 	return nil
 }
 
+// ReloadEvery returns the config polling interval, or 0 if reloading is disabled.
+func (cfg *MainConfig) ReloadEvery() time.Duration {
+	switch {
+	case cfg.ReloadInterval < 0:
+		return 0
+	case cfg.ReloadInterval == 0:
+		return DefaultReloadInterval
+	default:
+		return time.Duration(cfg.ReloadInterval) * time.Second
+	}
+}
+
+// Diff returns a human-readable summary of the differences between two
+// configs. Adapter configs are compared by their JSON representation,
+// secrets are never printed.
+func (cfg *MainConfig) Diff(other *MainConfig) []string {
+	var diff []string
+	if cfg.LLM.APIKey != other.LLM.APIKey {
+		diff = append(diff, "llm.api_key: changed")
+	}
+	if cfg.LLM.Endpoint != other.LLM.Endpoint {
+		diff = append(diff, fmt.Sprintf("llm.endpoint: %q -> %q", cfg.LLM.Endpoint, other.LLM.Endpoint))
+	}
+	if cfg.LLM.Model != other.LLM.Model {
+		diff = append(diff, fmt.Sprintf("llm.model: %q -> %q", cfg.LLM.Model, other.LLM.Model))
+	}
+	if cfg.LLM.Vision != other.LLM.Vision {
+		diff = append(diff, fmt.Sprintf("llm.vision: %t -> %t", cfg.LLM.Vision, other.LLM.Vision))
+	}
+	for _, name := range cfg.ChangedPlugins(other) {
+		diff = append(diff, fmt.Sprintf("filters.plugins.%s: changed", name))
+	}
+	if cfg.ReloadInterval != other.ReloadInterval {
+		diff = append(diff, fmt.Sprintf("reload_interval: %d -> %d", cfg.ReloadInterval, other.ReloadInterval))
+	}
+	return diff
+}
+
+// ChangedPlugins returns the sorted names of filter plugins added, removed
+// or changed in other.
+func (cfg *MainConfig) ChangedPlugins(other *MainConfig) []string {
+	var changed []string
+	for name, plugin := range other.Filters.Plugins {
+		if previous, ok := cfg.Filters.Plugins[name]; !ok || !reflect.DeepEqual(previous, plugin) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range cfg.Filters.Plugins {
+		if _, ok := other.Filters.Plugins[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
 func (cfg *MainConfig) Save(configPath string) error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	if err = os.WriteFile(configPath, data, 0644); err != nil {
+		return err
+	}
+	cfg.digest = utilities.Digest(data)
+	return nil
+}
+
+// ChangedOnDisk reports whether the file differs from the content last
+// loaded or saved, e.g. after an edit that was not applied.
+func (cfg *MainConfig) ChangedOnDisk(configPath string) (bool, error) {
+	digest, err := utilities.FileDigest(configPath)
+	if err != nil {
+		return false, err
+	}
+	return digest != cfg.digest, nil
+}
+
+// Adopt takes over the file state of other after its content was applied,
+// so it is not considered changed on disk anymore.
+func (cfg *MainConfig) Adopt(other *MainConfig) {
+	cfg.digest = other.digest
 }
 
 func (cfg *MainConfig) CreateDefault(configPath string) error {
@@ -60,9 +148,6 @@ func (cfg *MainConfig) CreateDefault(configPath string) error {
 	}
 
 	cfg.Adapters.Configs = make(map[string]interface{})
-	for _, adapterName := range adapters.RegisteredAdapters() {
-		cfg.Adapters.Configs[adapterName] = adapters.NewAdapterDefaultConfig(adapterName)
-	}
 
 	return cfg.Save(configPath)
 }
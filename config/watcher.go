@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Watcher polls files matching glob patterns and calls a handler when a file
+// is created or modified. Polling keeps it free of platform-specific
+// notification APIs.
+type Watcher struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	watches []watch
+	stamps  map[string]fileStamp
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type watch struct {
+	pattern  string
+	onChange func(path string)
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func NewWatcher(interval time.Duration) *Watcher {
+	return &Watcher{
+		interval: interval,
+		stamps:   make(map[string]fileStamp),
+	}
+}
+
+// Watch registers a handler for every file matching the glob pattern.
+// Files existing at registration time are not reported until they change.
+func (w *Watcher) Watch(pattern string, onChange func(path string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.watches = append(w.watches, watch{pattern: pattern, onChange: onChange})
+	for _, path := range glob(pattern) {
+		if stamp, ok := statFile(path); ok {
+			w.stamps[path] = stamp
+		}
+	}
+}
+
+// Poll checks all watched files once and calls the handlers of changed files.
+func (w *Watcher) Poll() {
+	w.mu.Lock()
+	var changed []func()
+	for _, wt := range w.watches {
+		for _, path := range glob(wt.pattern) {
+			stamp, ok := statFile(path)
+			if !ok {
+				continue
+			}
+			if prev, seen := w.stamps[path]; seen && prev == stamp {
+				continue
+			}
+			w.stamps[path] = stamp
+			onChange, path := wt.onChange, path
+			changed = append(changed, func() { onChange(path) })
+		}
+	}
+	w.mu.Unlock()
+
+	for _, call := range changed {
+		call()
+	}
+}
+
+// Start polls in the background until Stop is called.
+func (w *Watcher) Start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.Poll()
+			}
+		}
+	}()
+}
+
+func (w *Watcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+func glob(pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+	return matches
+}
+
+func statFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, true
+}
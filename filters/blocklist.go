@@ -0,0 +1,234 @@
+package filters
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// leetspeak maps digits used in place of letters.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+}
+
+// leetSymbols maps symbols used in place of letters. They are only folded
+// when followed by a letter, so punctuation ending a word stays punctuation.
+var leetSymbols = map[rune]rune{
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't',
+}
+
+// confusables maps common look-alike letters from other scripts to Latin.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x',
+	// Latin look-alikes
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'ß': 's',
+}
+
+// normalizedRune is a run of a rune of normalized text, with the number of
+// repeats and the span of the original text it came from.
+type normalizedRune struct {
+	r          rune
+	n          int
+	start, end int
+}
+
+// normalize lowercases the text and folds confusables, fullwidth forms and
+// leetspeak to plain letters. Repeated letters and whitespace are collapsed
+// into runs.
+func normalize(input string) []normalizedRune {
+	var out []normalizedRune
+	for i, r := range input {
+		end := i + utf8.RuneLen(r)
+		if folded, ok := leetSymbols[r]; ok {
+			if next, _ := utf8.DecodeRuneInString(input[end:]); isWordRune(foldRune(next)) {
+				r = folded
+			}
+		}
+		r = foldRune(r)
+		if r == 0 {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].r == r && (unicode.IsLetter(r) || r == ' ') {
+			out[n-1].n++
+			out[n-1].end = end
+			continue
+		}
+		out = append(out, normalizedRune{r: r, n: 1, start: i, end: end})
+	}
+	return out
+}
+
+func foldRune(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		// Fullwidth ASCII
+		r -= 0xFEE0
+	}
+	if unicode.IsSpace(r) {
+		return ' '
+	}
+	if isZeroWidth(r) || isJoiner(r) {
+		return 0
+	}
+	r = unicode.ToLower(r)
+	if folded, ok := confusables[r]; ok {
+		return folded
+	}
+	if folded, ok := leetspeak[r]; ok {
+		return folded
+	}
+	return r
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// BlocklistFilter masks, removes or rejects blocked words and phrases,
+// matched after normalizing both sides, so "H3LLLO" matches "hello". Double
+// letters of entries must be repeated in the text, so "as" does not match "ass".
+type BlocklistFilter struct {
+	entries [][]normalizedRune
+	action  string
+	mask    rune
+}
+
+// newBlocklistFilter is a method so list files resolve against the base directory.
+func (r *Registry) newBlocklistFilter(params Params) (Filter, error) {
+	p := struct {
+		Files  []string `json:"files"` // Relative to the config directory
+		Words  []string `json:"words"`
+		Action string   `json:"action"` // "mask", "remove" or "reject"
+		Mask   string   `json:"mask"`
+	}{
+		Action: "mask",
+		Mask:   "*",
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	switch p.Action {
+	case "mask", "remove", "reject":
+	default:
+		return nil, fmt.Errorf("unknown action %q, expected mask, remove or reject", p.Action)
+	}
+	if utf8.RuneCountInString(p.Mask) != 1 {
+		return nil, errors.New("mask must be a single character")
+	}
+
+	words := p.Words
+	for _, file := range p.Files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(r.baseDir, file)
+		}
+		loaded, err := loadWordList(file)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, loaded...)
+	}
+
+	filter := BlocklistFilter{action: p.Action}
+	filter.mask, _ = utf8.DecodeRuneInString(p.Mask)
+	for _, word := range words {
+		if entry := normalize(strings.TrimSpace(word)); len(entry) > 0 {
+			filter.entries = append(filter.entries, entry)
+		}
+	}
+	if len(filter.entries) == 0 {
+		return nil, errors.New("blocklist is empty")
+	}
+	return filter, nil
+}
+
+// loadWordList reads one entry per line, skipping blank lines and # comments.
+func loadWordList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open word list: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read word list %s: %w", path, err)
+	}
+	return words, nil
+}
+
+func (b BlocklistFilter) Apply(input string, _ Meta) (string, error) {
+	text := normalize(input)
+
+	// Find the original spans of whole-word matches
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(text); i++ {
+		if i > 0 && isWordRune(text[i-1].r) {
+			continue
+		}
+		for _, entry := range b.entries {
+			end := i + len(entry)
+			if end > len(text) || (end < len(text) && isWordRune(text[end].r)) || !matchesAt(text[i:end], entry) {
+				continue
+			}
+			spans = append(spans, span{text[i].start, text[end-1].end})
+			i = end - 1
+			break
+		}
+	}
+	if len(spans) == 0 {
+		return input, nil
+	}
+	if b.action == "reject" {
+		return "", Reject(b.Name(), "blocked word")
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, s := range spans {
+		out.WriteString(input[last:s.start])
+		last = s.end
+		if b.action == "mask" {
+			out.WriteString(strings.Repeat(string(b.mask), utf8.RuneCountInString(input[s.start:s.end])))
+		} else if (out.Len() == 0 || strings.HasSuffix(out.String(), " ")) && strings.HasPrefix(input[last:], " ") {
+			// Collapse the space left behind
+			last++
+		}
+	}
+	out.WriteString(input[last:])
+	if b.action == "remove" {
+		return strings.TrimSpace(out.String()), nil
+	}
+	return out.String(), nil
+}
+
+// matchesAt reports whether the text starts with the entry, each run of the
+// text repeating its letter at least as often as the entry.
+func matchesAt(text []normalizedRune, entry []normalizedRune) bool {
+	for i, e := range entry {
+		if text[i].r != e.r || (e.r != ' ' && text[i].n < e.n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b BlocklistFilter) Name() string {
+	return "blocklist"
+}
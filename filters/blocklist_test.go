@@ -0,0 +1,62 @@
+package filters
+
+import "testing"
+
+func TestBlocklistFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		input string
+		want  string
+	}{
+		{"plain", []string{"oats"}, "I love oats", "I love ****"},
+		{"case", []string{"oats"}, "OATS!", "****!"},
+		{"repeated letters", []string{"hello"}, "H3LLLOOO there", "******** there"},
+		{"leetspeak", []string{"oats"}, "0@t5 please", "**** please"},
+		{"confusables", []string{"oats"}, "оаts", "****"},
+		{"phrase", []string{"bad word"}, "a bad   word here", "a ********** here"},
+		{"whole words", []string{"oat"}, "goats and oatmeal", "goats and oatmeal"},
+
+		// Double letters of entries must be repeated in the text
+		{"double letter entry", []string{"ass"}, "as you wish", "as you wish"},
+		{"double letter entry matched", []string{"ass"}, "what an asss", "what an ****"},
+		{"double letter at end", []string{"butt"}, "but not now", "but not now"},
+		{"single letter text", []string{"hello"}, "helo", "helo"},
+	}
+
+	registry := &Registry{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := registry.newBlocklistFilter(Params{"words": tt.words})
+			if err != nil {
+				t.Fatalf("newBlocklistFilter: %v", err)
+			}
+			got, err := filter.Apply(tt.input, Meta{})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlocklistFilterActions(t *testing.T) {
+	registry := &Registry{}
+	remove, err := registry.newBlocklistFilter(Params{"words": []string{"oats"}, "action": "remove"})
+	if err != nil {
+		t.Fatalf("newBlocklistFilter: %v", err)
+	}
+	if got, _ := remove.Apply("oats are tasty oats", Meta{}); got != "are tasty" {
+		t.Errorf("remove = %q, want %q", got, "are tasty")
+	}
+
+	reject, err := registry.newBlocklistFilter(Params{"words": []string{"oats"}, "action": "reject"})
+	if err != nil {
+		t.Fatalf("newBlocklistFilter: %v", err)
+	}
+	if _, err := reject.Apply("more oats", Meta{}); err == nil {
+		t.Error("reject accepted a blocked word")
+	}
+}
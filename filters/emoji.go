@@ -1,15 +1,221 @@
 package filters
 
-import "github.com/dlclark/regexp2"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
 
-type EmojiFilter struct{}
+// customEmojiRegex matches Discord custom emoji like <:name:id> and <a:name:id>.
+var customEmojiRegex = regexp.MustCompile(`^<a?:(\w{2,32}):\d{17,20}>`)
 
-func (e EmojiFilter) Apply(input string) string {
-	emojiRegex := regexp2.MustCompile(`[\p{So}\p{Sk}\p{Sc}\p{Sm}\p{S}]`, regexp2.None)
-	result, _ := emojiRegex.Replace(input, "", -1, -1)
-	return result
+const (
+	emojiRemove     = "remove"      // Remove Unicode and custom emoji
+	emojiKeepCustom = "keep_custom" // Remove Unicode emoji, keep custom emoji
+	emojiShortcode  = "shortcode"   // Convert both to :shortcode:
+)
+
+// EmojiFilter removes emoji based on Unicode emoji properties, handling ZWJ
+// sequences, skin tone modifiers, keycaps and flags as single units. Symbols
+// defaulting to text presentation, like © or ↔, are kept unless followed by
+// the emoji variation selector. Currency signs and math operators are kept.
+type EmojiFilter struct {
+	mode string
+}
+
+func newEmojiFilter(params Params) (Filter, error) {
+	p := struct {
+		Mode string `json:"mode"`
+	}{
+		Mode: emojiRemove,
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	switch p.Mode {
+	case emojiRemove, emojiKeepCustom, emojiShortcode:
+	default:
+		return nil, fmt.Errorf("unknown mode %q, expected %s, %s or %s", p.Mode, emojiRemove, emojiKeepCustom, emojiShortcode)
+	}
+	return EmojiFilter{mode: p.Mode}, nil
+}
+
+func (e EmojiFilter) Apply(input string, _ Meta) (string, error) {
+	runes := []rune(input)
+	var b strings.Builder
+	b.Grow(len(input))
+
+	removedAtEnd := false
+	for i := 0; i < len(runes); {
+		var replacement string
+		n := 0
+
+		if runes[i] == '<' {
+			if match := customEmojiRegex.FindStringSubmatch(string(runes[i:min(len(runes), i+64)])); match != nil {
+				n = len([]rune(match[0]))
+				switch e.mode {
+				case emojiKeepCustom:
+					replacement = match[0]
+				case emojiShortcode:
+					replacement = ":" + match[1] + ":"
+				}
+			}
+		} else if n = emojiSequenceLength(runes, i); n > 0 {
+			if e.mode == emojiShortcode {
+				replacement = emojiShortcodeFor(runes[i : i+n])
+			}
+		} else if isEmojiComponent(runes[i]) {
+			// Dangling joiners, selectors and modifiers
+			n = 1
+		}
+
+		if n == 0 {
+			b.WriteRune(runes[i])
+			removedAtEnd = false
+			i++
+			continue
+		}
+
+		i += n
+		if replacement != "" {
+			b.WriteString(replacement)
+			removedAtEnd = false
+			continue
+		}
+
+		// Avoid leaving double spaces where the emoji was
+		removedAtEnd = true
+		out := b.String()
+		if i < len(runes) && runes[i] == ' ' && (out == "" || strings.HasSuffix(out, " ")) {
+			i++
+		}
+	}
+
+	result := b.String()
+	if removedAtEnd {
+		result = strings.TrimRight(result, " ")
+	}
+	return result, nil
 }
 
 func (e EmojiFilter) Name() string {
 	return "remove_emojis"
 }
+
+// IsEmoji reports whether s is a single emoji sequence, e.g. "👍🏽" or "🇫🇷".
+func IsEmoji(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 || (isRegionalIndicator(runes[0]) && len(runes) != 2) {
+		return false
+	}
+	return emojiSequenceLength(runes, 0) == len(runes)
+}
+
+// emojiSequenceLength returns the number of runes of the emoji sequence
+// starting at i, or 0 if none starts there.
+func emojiSequenceLength(runes []rune, i int) int {
+	r := runes[i]
+	n := len(runes)
+
+	// Keycaps like 1️⃣
+	if (r >= '0' && r <= '9') || r == '#' || r == '*' {
+		j := i + 1
+		if j < n && runes[j] == emojiSelector {
+			j++
+		}
+		if j < n && runes[j] == keycapMark {
+			return j + 1 - i
+		}
+		return 0
+	}
+
+	// Flags are pairs of regional indicators
+	if isRegionalIndicator(r) {
+		if i+1 < n && isRegionalIndicator(runes[i+1]) {
+			return 2
+		}
+		return 1
+	}
+
+	if !unicode.Is(extendedPictographic, r) {
+		return 0
+	}
+	if unicode.Is(textPresentation, r) && (i+1 >= n || runes[i+1] != emojiSelector) {
+		return 0
+	}
+
+	j := i + 1
+	for {
+		// Selectors, skin tones and tag sequences attach to the element
+		for j < n && (runes[j] == emojiSelector || isSkinTone(runes[j]) || isTag(runes[j])) {
+			j++
+		}
+		if j+1 < n && runes[j] == zeroWidthJoiner && unicode.Is(extendedPictographic, runes[j+1]) {
+			j += 2
+			continue
+		}
+		return j - i
+	}
+}
+
+// emojiShortcodeFor returns the shortcode of an emoji sequence, falling back
+// to its first element, or an empty string if unknown.
+func emojiShortcodeFor(sequence []rune) string {
+	if isRegionalIndicator(sequence[0]) {
+		if len(sequence) < 2 {
+			return ""
+		}
+		return fmt.Sprintf(":flag_%c%c:", 'a'+sequence[0]-regionalIndicator, 'a'+sequence[1]-regionalIndicator)
+	}
+
+	var base, first strings.Builder
+	skinTone := 0
+	inFirst := true
+	for _, r := range sequence {
+		switch {
+		case r == emojiSelector || r == textSelector || isTag(r):
+			continue
+		case isSkinTone(r):
+			if skinTone == 0 {
+				skinTone = int(r-skinToneFirst) + 1
+			}
+			continue
+		case r == zeroWidthJoiner:
+			inFirst = false
+		}
+		base.WriteRune(r)
+		if inFirst {
+			first.WriteRune(r)
+		}
+	}
+
+	name, ok := emojiShortcodes[base.String()]
+	if !ok {
+		name, ok = emojiShortcodes[first.String()]
+	}
+	if !ok {
+		return ""
+	}
+	if skinTone > 0 {
+		return fmt.Sprintf(":%s::skin-tone-%d:", name, skinTone)
+	}
+	return ":" + name + ":"
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndicator && r <= regionalIndicator+25
+}
+
+func isSkinTone(r rune) bool {
+	return r >= skinToneFirst && r <= skinToneLast
+}
+
+func isTag(r rune) bool {
+	return r >= tagFirst && r <= tagCancel
+}
+
+func isEmojiComponent(r rune) bool {
+	return r == zeroWidthJoiner || r == emojiSelector || r == textSelector ||
+		r == keycapMark || isSkinTone(r) || isTag(r)
+}
@@ -0,0 +1,101 @@
+package filters
+
+import "unicode"
+
+// extendedPictographic lists the Extended_Pictographic code points of
+// Unicode's emoji-data.txt.
+var extendedPictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00A9, 0x00A9, 1}, {0x00AE, 0x00AE, 1}, {0x203C, 0x203C, 1}, {0x2049, 0x2049, 1},
+		{0x2122, 0x2122, 1}, {0x2139, 0x2139, 1}, {0x2194, 0x2199, 1}, {0x21A9, 0x21AA, 1},
+		{0x231A, 0x231B, 1}, {0x2328, 0x2328, 1}, {0x2388, 0x2388, 1}, {0x23CF, 0x23CF, 1},
+		{0x23E9, 0x23F3, 1}, {0x23F8, 0x23FA, 1}, {0x24C2, 0x24C2, 1}, {0x25AA, 0x25AB, 1},
+		{0x25B6, 0x25B6, 1}, {0x25C0, 0x25C0, 1}, {0x25FB, 0x25FE, 1}, {0x2600, 0x2605, 1},
+		{0x2607, 0x2612, 1}, {0x2614, 0x2685, 1}, {0x2690, 0x2705, 1}, {0x2708, 0x2712, 1},
+		{0x2714, 0x2714, 1}, {0x2716, 0x2716, 1}, {0x271D, 0x271D, 1}, {0x2721, 0x2721, 1},
+		{0x2728, 0x2728, 1}, {0x2733, 0x2734, 1}, {0x2744, 0x2744, 1}, {0x2747, 0x2747, 1},
+		{0x274C, 0x274C, 1}, {0x274E, 0x274E, 1}, {0x2753, 0x2755, 1}, {0x2757, 0x2757, 1},
+		{0x2763, 0x2767, 1}, {0x2795, 0x2797, 1}, {0x27A1, 0x27A1, 1}, {0x27B0, 0x27B0, 1},
+		{0x27BF, 0x27BF, 1}, {0x2934, 0x2935, 1}, {0x2B05, 0x2B07, 1}, {0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B50, 1}, {0x2B55, 0x2B55, 1}, {0x3030, 0x3030, 1}, {0x303D, 0x303D, 1},
+		{0x3297, 0x3297, 1}, {0x3299, 0x3299, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1F000, 0x1F0FF, 1}, {0x1F10D, 0x1F10F, 1}, {0x1F12F, 0x1F12F, 1}, {0x1F16C, 0x1F171, 1},
+		{0x1F17E, 0x1F17F, 1}, {0x1F18E, 0x1F18E, 1}, {0x1F191, 0x1F19A, 1}, {0x1F1AD, 0x1F1E5, 1},
+		{0x1F201, 0x1F20F, 1}, {0x1F21A, 0x1F21A, 1}, {0x1F22F, 0x1F22F, 1}, {0x1F232, 0x1F23A, 1},
+		{0x1F23C, 0x1F23F, 1}, {0x1F249, 0x1F3FA, 1}, {0x1F400, 0x1F53D, 1}, {0x1F546, 0x1F64F, 1},
+		{0x1F680, 0x1F6FF, 1}, {0x1F774, 0x1F77F, 1}, {0x1F7D5, 0x1F7FF, 1}, {0x1F80C, 0x1F80F, 1},
+		{0x1F848, 0x1F84F, 1}, {0x1F85A, 0x1F85F, 1}, {0x1F888, 0x1F88F, 1}, {0x1F8AE, 0x1F8FF, 1},
+		{0x1F90C, 0x1F93A, 1}, {0x1F93C, 0x1F945, 1}, {0x1F947, 0x1FAFF, 1}, {0x1FC00, 0x1FFFD, 1},
+	},
+}
+
+// textPresentation lists the pictographic code points below U+1F000 that
+// default to text presentation, like © and ↔. They only count as emoji when
+// followed by VS16 (U+FE0F).
+var textPresentation = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00A9, 0x00A9, 1}, {0x00AE, 0x00AE, 1}, {0x203C, 0x203C, 1}, {0x2049, 0x2049, 1},
+		{0x2122, 0x2122, 1}, {0x2139, 0x2139, 1}, {0x2194, 0x2199, 1}, {0x21A9, 0x21AA, 1},
+		{0x2328, 0x2328, 1}, {0x2388, 0x2388, 1}, {0x23CF, 0x23CF, 1}, {0x23ED, 0x23EF, 1},
+		{0x23F1, 0x23F2, 1}, {0x23F8, 0x23FA, 1}, {0x24C2, 0x24C2, 1}, {0x25AA, 0x25AB, 1},
+		{0x25B6, 0x25B6, 1}, {0x25C0, 0x25C0, 1}, {0x25FB, 0x25FC, 1}, {0x2600, 0x2605, 1},
+		{0x2607, 0x2613, 1}, {0x2616, 0x2647, 1}, {0x2654, 0x267E, 1}, {0x2680, 0x2692, 1},
+		{0x2694, 0x26A0, 1}, {0x26A2, 0x26A9, 1}, {0x26AC, 0x26BC, 1}, {0x26BF, 0x26C3, 1},
+		{0x26C6, 0x26CD, 1}, {0x26CF, 0x26D3, 1}, {0x26D5, 0x26E9, 1}, {0x26EB, 0x26F1, 1},
+		{0x26F4, 0x26F4, 1}, {0x26F6, 0x26F9, 1}, {0x26FB, 0x26FC, 1}, {0x26FE, 0x2704, 1},
+		{0x2708, 0x2709, 1}, {0x270C, 0x2712, 1}, {0x2714, 0x2714, 1}, {0x2716, 0x2716, 1},
+		{0x271D, 0x271D, 1}, {0x2721, 0x2721, 1}, {0x2733, 0x2734, 1}, {0x2744, 0x2744, 1},
+		{0x2747, 0x2747, 1}, {0x2763, 0x2767, 1}, {0x27A1, 0x27A1, 1}, {0x2934, 0x2935, 1},
+		{0x2B05, 0x2B07, 1}, {0x3030, 0x3030, 1}, {0x303D, 0x303D, 1}, {0x3297, 0x3297, 1},
+		{0x3299, 0x3299, 1},
+	},
+}
+
+// Emoji sequence components.
+const (
+	zeroWidthJoiner   = '\u200D'
+	textSelector      = '\uFE0E'
+	emojiSelector     = '\uFE0F'
+	keycapMark        = '\u20E3'
+	regionalIndicator = 0x1F1E6 // 'A', up to 0x1F1FF for 'Z'
+	skinToneFirst     = 0x1F3FB
+	skinToneLast      = 0x1F3FF
+	tagFirst          = 0xE0020
+	tagCancel         = 0xE007F
+)
+
+// emojiShortcodes maps common emoji, without variation selectors, to the
+// shortcodes Discord uses.
+var emojiShortcodes = map[string]string{
+	"😀": "grinning", "😃": "smiley", "😄": "smile", "😁": "grin", "😆": "laughing",
+	"😅": "sweat_smile", "🤣": "rofl", "😂": "joy", "🙂": "slight_smile", "🙃": "upside_down",
+	"😉": "wink", "😊": "blush", "😇": "innocent", "🥰": "smiling_face_with_3_hearts", "😍": "heart_eyes",
+	"🤩": "star_struck", "😘": "kissing_heart", "😋": "yum", "😛": "stuck_out_tongue", "😜": "stuck_out_tongue_winking_eye",
+	"🤪": "zany_face", "🤔": "thinking", "🤗": "hugging", "🤭": "face_with_hand_over_mouth", "🤫": "shushing_face",
+	"😐": "neutral_face", "😑": "expressionless", "😶": "no_mouth", "😏": "smirk", "😒": "unamused",
+	"🙄": "rolling_eyes", "😬": "grimacing", "😌": "relieved", "😔": "pensive", "😴": "sleeping",
+	"😷": "mask", "🤒": "thermometer_face", "🤢": "nauseated_face", "🤮": "face_vomiting", "🥵": "hot_face",
+	"🥶": "cold_face", "😵": "dizzy_face", "🤯": "exploding_head", "🤠": "cowboy", "🥳": "partying_face",
+	"😎": "sunglasses", "🤓": "nerd", "😕": "confused", "😟": "worried", "🙁": "slight_frown",
+	"😮": "open_mouth", "😲": "astonished", "😳": "flushed", "🥺": "pleading_face", "😢": "cry",
+	"😭": "sob", "😱": "scream", "😖": "confounded", "😞": "disappointed", "😓": "sweat",
+	"😩": "weary", "😫": "tired_face", "🥱": "yawning_face", "😤": "triumph", "😡": "rage",
+	"😠": "angry", "🤬": "face_with_symbols_over_mouth", "😈": "smiling_imp", "💀": "skull", "💩": "poop",
+	"🤡": "clown", "👻": "ghost", "👽": "alien", "🤖": "robot", "😺": "smiley_cat",
+	"👋": "wave", "👌": "ok_hand", "✌": "v", "🤞": "fingers_crossed", "🤟": "love_you_gesture",
+	"🤘": "metal", "👈": "point_left", "👉": "point_right", "👆": "point_up_2", "👇": "point_down",
+	"👍": "thumbsup", "👎": "thumbsdown", "✊": "fist", "👊": "punch", "👏": "clap",
+	"🙌": "raised_hands", "🙏": "pray", "💪": "muscle", "👀": "eyes", "🧠": "brain",
+	"❤": "heart", "🧡": "orange_heart", "💛": "yellow_heart", "💚": "green_heart", "💙": "blue_heart",
+	"💜": "purple_heart", "🖤": "black_heart", "💔": "broken_heart", "💯": "100", "💢": "anger",
+	"💥": "boom", "💫": "dizzy", "💦": "sweat_drops", "💤": "zzz", "✨": "sparkles",
+	"🔥": "fire", "⭐": "star", "🌟": "star2", "🎉": "tada", "🎂": "birthday",
+	"🍰": "cake", "🥕": "carrot", "🍎": "apple", "🐴": "horse", "🐎": "racehorse",
+	"🦄": "unicorn", "🐶": "dog", "🐱": "cat", "👑": "crown", "✅": "white_check_mark",
+	"❌": "x", "❓": "question", "❗": "exclamation", "⚠": "warning", "🚀": "rocket",
+	"🏳\u200D🌈": "rainbow_flag", "🏴\u200D☠": "pirate_flag", "❤\u200D🔥": "heart_on_fire",
+	"#\u20E3": "hash", "*\u20E3": "asterisk", "0\u20E3": "zero", "1\u20E3": "one", "2\u20E3": "two", "3\u20E3": "three",
+	"4\u20E3": "four", "5\u20E3": "five", "6\u20E3": "six", "7\u20E3": "seven", "8\u20E3": "eight", "9\u20E3": "nine",
+}
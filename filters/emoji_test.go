@@ -0,0 +1,74 @@
+package filters
+
+import "testing"
+
+func TestEmojiFilter(t *testing.T) {
+	const custom = "<:neigh:123456789012345678>"
+	tests := []struct {
+		name  string
+		mode  string
+		input string
+		want  string
+	}{
+		// Sequences are removed as single units
+		{"plain", emojiRemove, "hello 🐴 world", "hello world"},
+		{"zwj family", emojiRemove, "family 👨‍👩‍👧‍👦 here", "family here"},
+		{"zwj profession", emojiRemove, "coder 👩🏽‍💻 at work", "coder at work"},
+		{"skin tone", emojiRemove, "ok 👍🏽", "ok"},
+		{"all skin tones", emojiRemove, "👋🏻👋🏼👋🏽👋🏾👋🏿!", "!"},
+		{"flag", emojiRemove, "flag 🇫🇷 yes", "flag yes"},
+		{"flag pair", emojiRemove, "🇩🇪🇳🇱 neighbours", "neighbours"},
+		{"zwj flag", emojiRemove, "🏳️‍🌈 pride", "pride"},
+		{"tag flag", emojiRemove, "go \U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F team", "go team"},
+		{"keycap", emojiRemove, "keycap 1️⃣ two", "keycap two"},
+		{"keycap without selector", emojiRemove, "press #⃣ now", "press now"},
+		{"heart with selector", emojiRemove, "❤️ love", "love"},
+		{"dangling joiner", emojiRemove, "a\u200Db", "ab"},
+		{"custom emoji", emojiRemove, "a" + custom + "b", "ab"},
+
+		// Text presentation symbols are emoji only with VS16
+		{"copyright text", emojiRemove, "© 2024", "© 2024"},
+		{"copyright emoji", emojiRemove, "©️ 2024", "2024"},
+		{"arrow text and emoji", emojiRemove, "↔ vs ↔️", "↔ vs"},
+		{"trademark text", emojiRemove, "NeighBot™", "NeighBot™"},
+
+		// Currency, math and other symbols are not emoji
+		{"currency", emojiRemove, "€100, £5, ¥300 and $2", "€100, £5, ¥300 and $2"},
+		{"math", emojiRemove, "3 × 2 ≤ 7, ∑ ≠ ∞, √x ± 1", "3 × 2 ≤ 7, ∑ ≠ ∞, √x ± 1"},
+		{"digits and punctuation", emojiRemove, "call #1 *now*", "call #1 *now*"},
+
+		{"keep custom", emojiKeepCustom, "hi " + custom + " 🐴", "hi " + custom},
+		{"keep custom animated", emojiKeepCustom, "<a:gallop:123456789012345678>😀", "<a:gallop:123456789012345678>"},
+
+		{"shortcode", emojiShortcode, "hi 🐴 " + custom, "hi :horse: :neigh:"},
+		{"shortcode skin tone", emojiShortcode, "👍🏽", ":thumbsup::skin-tone-3:"},
+		{"shortcode flag", emojiShortcode, "🇫🇷", ":flag_fr:"},
+		{"shortcode keycap", emojiShortcode, "1️⃣", ":one:"},
+		{"shortcode zwj", emojiShortcode, "🏳️‍🌈", ":rainbow_flag:"},
+		{"shortcode selector", emojiShortcode, "❤️", ":heart:"},
+		{"shortcode unknown", emojiShortcode, "zebra 🦓", "zebra"},
+		{"shortcode text presentation", emojiShortcode, "© 2024", "© 2024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newEmojiFilter(Params{"mode": tt.mode})
+			if err != nil {
+				t.Fatalf("newEmojiFilter: %v", err)
+			}
+			got, err := filter.Apply(tt.input, Meta{})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmojiFilterUnknownMode(t *testing.T) {
+	if _, err := newEmojiFilter(Params{"mode": "explode"}); err == nil {
+		t.Error("newEmojiFilter accepted an unknown mode")
+	}
+}
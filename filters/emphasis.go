@@ -1,12 +1,14 @@
 package filters
 
-import "regexp"
+import "NeighBot/markdown"
 
+// EmphasisFilter removes roleplay actions like *waves*. Other formatting,
+// bullet lists, math like 2*3*4 and code are left intact.
 type EmphasisFilter struct{}
 
-func (e EmphasisFilter) Apply(input string) string {
-	emphasisRegex := regexp.MustCompile(`\*.*?\*`) // Matches text between asterisks
-	return emphasisRegex.ReplaceAllString(input, "")
+func (e EmphasisFilter) Apply(input string, _ Meta) (string, error) {
+	tokens := markdown.RemoveActions(markdown.Parse(input))
+	return markdown.Render(tokens, markdown.Discord), nil
 }
 
 func (e EmphasisFilter) Name() string {
@@ -0,0 +1,186 @@
+package filters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultExecTimeout = 5 * time.Second
+
+	// execRejectStatus is the exit status of plugins rejecting the message,
+	// stderr holds the reason.
+	execRejectStatus = 2
+)
+
+// ExecConfig configures a filter backed by an external executable.
+type ExecConfig struct {
+	Command   []string `json:"command"`    // Executable and arguments, relative paths resolve against the config directory
+	TimeoutMs int      `json:"timeout_ms"` // Per call, 0 for the default
+	OnError   string   `json:"on_error"`   // "fail_open" passes the text through, "fail_closed" (default) rejects it
+}
+
+// Validate checks the config without starting the executable.
+func (c ExecConfig) Validate() error {
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return errors.New("command must not be empty")
+	}
+	if c.TimeoutMs < 0 {
+		return errors.New("timeout_ms must not be negative")
+	}
+	switch c.OnError {
+	case "", "fail_open", "fail_closed":
+		return nil
+	default:
+		return fmt.Errorf("unknown on_error %q, expected fail_open or fail_closed", c.OnError)
+	}
+}
+
+// execInput is written as JSON to the executable's stdin.
+type execInput struct {
+	Text   string `json:"text"`
+	Meta   Meta   `json:"meta"`
+	Params Params `json:"params,omitempty"`
+}
+
+// ExecFilter pipes the text through an external executable. It receives
+// execInput as JSON on stdin and writes the filtered text to stdout. Exiting
+// with status 2 rejects the message.
+type ExecFilter struct {
+	name     string
+	command  []string
+	dir      string
+	timeout  time.Duration
+	failOpen bool
+	params   Params
+}
+
+// ValidateExec checks a plugin filter before registering it. Plugins must
+// not shadow built-in filters.
+func (r *Registry) ValidateExec(name string, cfg ExecConfig) error {
+	r.mu.RLock()
+	_, exists := r.factories[name]
+	builtin := exists && !r.plugins[name]
+	r.mu.RUnlock()
+	if builtin {
+		return fmt.Errorf("filter plugin %s: name taken by a built-in filter", name)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("filter plugin %s: %w", name, err)
+	}
+	return nil
+}
+
+// RegisterExec registers a plugin filter backed by an external executable,
+// replacing a plugin of the same name. Params given to the filter in context
+// configs are passed on to it.
+func (r *Registry) RegisterExec(name string, cfg ExecConfig) error {
+	if err := r.ValidateExec(name, cfg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.plugins[name] = true
+	r.mu.Unlock()
+	r.RegisterFilter(name, func(params Params) (Filter, error) {
+		return r.newExecFilter(name, cfg, params), nil
+	})
+	return nil
+}
+
+// UnregisterExec removes a plugin filter. Built-in filters are kept.
+func (r *Registry) UnregisterExec(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.plugins[name] {
+		return
+	}
+	delete(r.plugins, name)
+	delete(r.factories, name)
+	r.log.Infow("Filter unregistered", "filter_name", name)
+}
+
+// Plugins returns the sorted names of the registered plugin filters.
+func (r *Registry) Plugins() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (r *Registry) newExecFilter(name string, cfg ExecConfig, params Params) ExecFilter {
+	command := append([]string(nil), cfg.Command...)
+	if strings.ContainsRune(command[0], filepath.Separator) && !filepath.IsAbs(command[0]) {
+		command[0] = filepath.Join(r.baseDir, command[0])
+	}
+	timeout := DefaultExecTimeout
+	if cfg.TimeoutMs > 0 {
+		timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	return ExecFilter{
+		name:     name,
+		command:  command,
+		dir:      r.baseDir,
+		timeout:  timeout,
+		failOpen: cfg.OnError == "fail_open",
+		params:   params,
+	}
+}
+
+func (e ExecFilter) Apply(input string, meta Meta) (string, error) {
+	output, err := e.run(input, meta)
+	var rejected *RejectError
+	if err == nil || errors.As(err, &rejected) {
+		return output, err
+	}
+	if e.failOpen {
+		return input, nil
+	}
+	return "", Reject(e.name, err.Error())
+}
+
+func (e ExecFilter) run(input string, meta Meta) (string, error) {
+	stdin, err := json.Marshal(execInput{Text: input, Meta: meta, Params: e.params})
+	if err != nil {
+		return "", err
+	}
+
+	goCtx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(goCtx, e.command[0], e.command[1:]...)
+	cmd.Dir = e.dir
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if goCtx.Err() != nil {
+		return "", fmt.Errorf("timed out after %s", e.timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == execRejectStatus {
+		return "", Reject(e.name, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func (e ExecFilter) Name() string {
+	return e.name
+}
@@ -1,10 +1,56 @@
 package filters
 
+import "fmt"
+
+// Direction tells whether a filter runs on user input or on model output.
+type Direction string
+
+const (
+	Inbound  Direction = "inbound"
+	Outbound Direction = "outbound"
+)
+
+// Meta describes the message being filtered.
+type Meta struct {
+	ContextID string    `json:"context_id"`
+	Direction Direction `json:"direction"`
+	Source    string    `json:"source,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Recorder  Recorder  `json:"-"`
+}
+
+// Recorder counts what filters redacted, for auditing.
+type Recorder interface {
+	Record(direction Direction, kind string, count int)
+}
+
+// Record reports count redactions of kind to the recorder, if any.
+func (m Meta) Record(kind string, count int) {
+	if m.Recorder != nil && count > 0 {
+		m.Recorder.Record(m.Direction, kind, count)
+	}
+}
+
 type Filter interface {
-	Apply(input string) string
+	// Apply returns the filtered input, or a *RejectError to drop the message.
+	Apply(input string, meta Meta) (string, error)
 	Name() string
 }
 
+// RejectError is returned by filters dropping a message entirely.
+type RejectError struct {
+	Filter string
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("rejected by %s: %s", e.Filter, e.Reason)
+}
+
+func Reject(filter, reason string) error {
+	return &RejectError{Filter: filter, Reason: reason}
+}
+
 type FilterManager struct {
 	filters []Filter
 }
@@ -13,9 +59,13 @@ func (fm *FilterManager) AddFilter(filter Filter) {
 	fm.filters = append(fm.filters, filter)
 }
 
-func (fm *FilterManager) Apply(input string) string {
+// Apply runs the filters in order, stopping at the first error.
+func (fm *FilterManager) Apply(input string, meta Meta) (string, error) {
 	for _, filter := range fm.filters {
-		input = filter.Apply(input)
+		var err error
+		if input, err = filter.Apply(input, meta); err != nil {
+			return "", err
+		}
 	}
-	return input
+	return input, nil
 }
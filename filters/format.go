@@ -0,0 +1,33 @@
+package filters
+
+import "NeighBot/markdown"
+
+// FormatFilter converts markdown to the formatting of the target platform.
+type FormatFilter struct {
+	dialect markdown.Dialect
+}
+
+func newFormatFilter(params Params) (Filter, error) {
+	p := struct {
+		Target string `json:"target"`
+	}{
+		Target: string(markdown.Discord),
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	dialect, err := markdown.ParseDialect(p.Target)
+	if err != nil {
+		return nil, err
+	}
+	return FormatFilter{dialect: dialect}, nil
+}
+
+func (f FormatFilter) Apply(input string, _ Meta) (string, error) {
+	return markdown.Render(markdown.Parse(input), f.dialect), nil
+}
+
+func (f FormatFilter) Name() string {
+	return "convert_markdown"
+}
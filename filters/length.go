@@ -0,0 +1,52 @@
+package filters
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// LengthFilter truncates or drops oversized messages, such as huge pastes.
+type LengthFilter struct {
+	maxChars int
+	drop     bool
+	suffix   string
+}
+
+func newLengthFilter(params Params) (Filter, error) {
+	p := struct {
+		MaxChars int    `json:"max_chars"`
+		Action   string `json:"action"` // "truncate" or "drop"
+		Suffix   string `json:"suffix"`
+	}{
+		Action: "truncate",
+		Suffix: "… [truncated]",
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.MaxChars < 1 {
+		return nil, fmt.Errorf("max_chars must be at least 1")
+	}
+	if p.Action != "truncate" && p.Action != "drop" {
+		return nil, fmt.Errorf("unknown action %q, expected truncate or drop", p.Action)
+	}
+
+	return LengthFilter{maxChars: p.MaxChars, drop: p.Action == "drop", suffix: p.Suffix}, nil
+}
+
+func (l LengthFilter) Apply(input string, _ Meta) (string, error) {
+	length := utf8.RuneCountInString(input)
+	if length <= l.maxChars {
+		return input, nil
+	}
+	if l.drop {
+		return "", Reject(l.Name(), fmt.Sprintf("message of %d characters exceeds %d", length, l.maxChars))
+	}
+
+	runes := []rune(input)
+	return string(runes[:l.maxChars]) + l.suffix, nil
+}
+
+func (l LengthFilter) Name() string {
+	return "max_length"
+}
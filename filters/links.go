@@ -1,14 +1,213 @@
 package filters
 
-import "regexp"
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
 
-type LinkFilter struct{}
+// linkRegex matches, in order of precedence, markdown links, URLs and bare
+// domains with a common top-level domain, capturing the domain's TLD and path.
+var linkRegex = regexp.MustCompile(`(?i)` +
+	`\[([^\]\n]*)\]\((https?://[^\s)]+)\)` +
+	`|(https?://[^\s<>]+)` +
+	`|\b((?:[a-z\d](?:[a-z\d-]*[a-z\d])?\.)+` +
+	`(com|net|org|io|gg|dev|app|co|me|tv|xyz|ly|info|biz|us|uk|de|fi|ru|jp|fr|ai|sh|to|cc|link|site|online|store)` +
+	`\b(/[^\s<>]*)?)`)
 
-func (l LinkFilter) Apply(input string) string {
-	linkRegex := regexp.MustCompile(`https?://\S+`) // Matches URLs
-	return linkRegex.ReplaceAllString(input, "")
+// fileExtensionTLDs are top-level domains that are also common file
+// extensions. Bare domains with them are only links with "www." or a path,
+// so "install.sh" stays.
+var fileExtensionTLDs = []string{"ai", "app", "cc", "co", "io", "me", "sh", "to"}
+
+var defaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid",
+	"igshid", "si", "ref_src", "ref_url", "_hsenc", "_hsmi", "yclid",
+}
+
+// LinkFilter removes links, keeping the ones allowed by domain. Without
+// allow or block lists every link is removed. With an allowlist only links
+// to allowed domains are kept, a blocklist removes links to blocked domains.
+// Subdomains match their parent domain. Kept links can be stripped of
+// tracking parameters, removed ones replaced with a placeholder.
+type LinkFilter struct {
+	allow          []string
+	block          []string
+	placeholder    string
+	stripTracking  bool
+	trackingParams []string
+}
+
+func newLinkFilter(params Params) (Filter, error) {
+	p := struct {
+		Allow          []string `json:"allow"`
+		Block          []string `json:"block"`
+		Placeholder    string   `json:"placeholder"`
+		StripTracking  bool     `json:"strip_tracking"`
+		TrackingParams []string `json:"tracking_params"`
+	}{
+		TrackingParams: defaultTrackingParams,
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	return LinkFilter{
+		allow:          normalizeDomains(p.Allow),
+		block:          normalizeDomains(p.Block),
+		placeholder:    p.Placeholder,
+		stripTracking:  p.StripTracking,
+		trackingParams: p.TrackingParams,
+	}, nil
+}
+
+func (l LinkFilter) Apply(input string, _ Meta) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, m := range linkRegex.FindAllStringSubmatchIndex(input, -1) {
+		start, end := m[0], m[1]
+
+		if m[8] >= 0 && !bareLink(input, m) {
+			continue
+		}
+
+		var text, link string
+		switch {
+		case m[2] >= 0:
+			text, link = input[m[2]:m[3]], input[m[4]:m[5]]
+		case m[6] >= 0:
+			link = input[m[6]:m[7]]
+		default:
+			link = input[m[8]:m[9]]
+		}
+
+		// Trailing punctuation belongs to the sentence, not the link
+		if m[2] < 0 {
+			trimmed := trimLinkPunctuation(link)
+			end -= len(link) - len(trimmed)
+			link = trimmed
+		}
+
+		b.WriteString(input[last:start])
+		b.WriteString(l.rewrite(text, link, m[2] >= 0))
+		last = end
+	}
+	b.WriteString(input[last:])
+	return b.String(), nil
+}
+
+func (l LinkFilter) rewrite(text, link string, markdown bool) string {
+	if !l.allowed(linkHost(link)) {
+		switch {
+		case markdown && l.placeholder != "":
+			return text + " (" + l.placeholder + ")"
+		case markdown:
+			return text
+		default:
+			return l.placeholder
+		}
+	}
+
+	if l.stripTracking {
+		link = l.removeTracking(link)
+	}
+	if markdown {
+		return "[" + text + "](" + link + ")"
+	}
+	return link
+}
+
+func (l LinkFilter) allowed(host string) bool {
+	if matchesDomain(host, l.block) {
+		return false
+	}
+	if len(l.allow) > 0 {
+		return matchesDomain(host, l.allow)
+	}
+	return len(l.block) > 0
+}
+
+func (l LinkFilter) removeTracking(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.RawQuery == "" {
+		return link
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		for _, param := range l.trackingParams {
+			prefix, isPrefix := strings.CutSuffix(param, "*")
+			if key == param || (isPrefix && strings.HasPrefix(key, prefix)) {
+				query.Del(key)
+				break
+			}
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
 }
 
 func (l LinkFilter) Name() string {
 	return "remove_links"
 }
+
+// bareLink reports whether a bare domain match is a link. Domains of email
+// addresses and file names in paths are not.
+func bareLink(input string, m []int) bool {
+	start := m[8]
+	if start > 0 && strings.IndexByte("@/.", input[start-1]) >= 0 {
+		return false
+	}
+	if !slices.Contains(fileExtensionTLDs, strings.ToLower(input[m[10]:m[11]])) {
+		return true
+	}
+	hasPath := m[12] >= 0 && m[13] > m[12]+1
+	return hasPath || strings.HasPrefix(strings.ToLower(input[start:m[9]]), "www.")
+}
+
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+func trimLinkPunctuation(link string) string {
+	for link != "" {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,!?;:'\"", last) >= 0:
+			link = link[:len(link)-1]
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+			link = link[:len(link)-1]
+		default:
+			return link
+		}
+	}
+	return link
+}
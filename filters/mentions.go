@@ -0,0 +1,36 @@
+package filters
+
+import "regexp"
+
+var (
+	massMentionRegex = regexp.MustCompile(`(?i)@(everyone|here)\b`)
+	roleMentionRegex = regexp.MustCompile(`<@&\d+>`)
+)
+
+// MentionSafetyFilter neutralizes mass mentions by putting a zero-width space
+// after the @, and replaces role mentions. It must run after
+// strip_zero_width, which would undo it.
+type MentionSafetyFilter struct {
+	rolePlaceholder string
+}
+
+func newMentionSafetyFilter(params Params) (Filter, error) {
+	p := struct {
+		RolePlaceholder string `json:"role_placeholder"`
+	}{
+		RolePlaceholder: "@role",
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return MentionSafetyFilter{rolePlaceholder: p.RolePlaceholder}, nil
+}
+
+func (m MentionSafetyFilter) Apply(input string, _ Meta) (string, error) {
+	input = massMentionRegex.ReplaceAllString(input, "@\u200B$1")
+	return roleMentionRegex.ReplaceAllLiteralString(input, m.rolePlaceholder), nil
+}
+
+func (m MentionSafetyFilter) Name() string {
+	return "mention_safety"
+}
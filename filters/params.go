@@ -0,0 +1,82 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Params holds the parameters of a filter as declared in a context config.
+type Params map[string]interface{}
+
+// Decode unmarshals the parameters into a typed struct, rejecting unknown ones.
+func (p Params) Decode(target interface{}) error {
+	if p == nil {
+		p = Params{}
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+// Spec declares a filter and its parameters in a context config.
+type Spec struct {
+	Name   string `json:"name"`
+	Params Params `json:"params,omitempty"`
+}
+
+// Specs is an ordered list of filters, applied in order.
+type Specs []Spec
+
+// UnmarshalJSON accepts the ordered list as well as the legacy
+// {"filter_name": enabled} map, whose enabled filters are sorted by name.
+func (s *Specs) UnmarshalJSON(data []byte) error {
+	var list []Spec
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+
+	var legacy map[string]bool
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("filters must be a list of {name, params} entries: %w", err)
+	}
+
+	names := make([]string, 0, len(legacy))
+	for name, enabled := range legacy {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	*s = make(Specs, 0, len(names))
+	for _, name := range names {
+		*s = append(*s, Spec{Name: name})
+	}
+	return nil
+}
+
+// Equal reports whether both lists declare the same filters with the same parameters.
+func (s Specs) Equal(other Specs) bool {
+	a, errA := json.Marshal(s)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+func (s Specs) String() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%d filters", len(s))
+	}
+	return string(data)
+}
@@ -0,0 +1,159 @@
+package filters
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	emailRegex = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+	cardRegex  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	ipv4Regex  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Regex  = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{1,4}\b`)
+	// Phone numbers need an international prefix, an area code in
+	// parentheses or the 3-3-4 grouping, so other runs of numbers stay
+	phoneRegex = regexp.MustCompile(`\+\d{1,3}[ .-]?(?:\(\d{1,4}\)[ .-]?)?\d{1,4}(?:[ .-]?\d{2,4}){1,4}\b` +
+		`|\(\d{2,4}\)[ .-]?\d{3,4}[ .-]?\d{3,4}\b` +
+		`|\b\d{3}[ .-]\d{3}[ .-]\d{4}\b`)
+
+	// versionRegex matches text introducing a version number, which looks
+	// like an IP address
+	versionRegex = regexp.MustCompile(`(?i)\b(?:v|ver|version)\.?\s*$`)
+)
+
+// piiKind is a kind of personal data, with a check weeding out look-alikes
+// by the match and the text before it.
+type piiKind struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match, before string) bool
+}
+
+// piiKinds in order of matching, so e.g. card numbers are not taken for phones.
+var piiKinds = []piiKind{
+	{name: "email", pattern: emailRegex},
+	{name: "credit_card", pattern: cardRegex, valid: luhnValid},
+	{name: "ip", pattern: ipv4Regex, valid: ipValid},
+	{name: "ip", pattern: ipv6Regex, valid: ipValid},
+	{name: "phone", pattern: phoneRegex, valid: phoneValid},
+}
+
+// PIIFilter redacts emails, phone numbers, IP addresses, credit card numbers
+// and secrets, recording the counts per kind.
+type PIIFilter struct {
+	kinds       map[string]bool
+	placeholder string
+}
+
+func newPIIFilter(params Params) (Filter, error) {
+	p := struct {
+		Kinds       []string `json:"kinds"`
+		Placeholder string   `json:"placeholder"` // "{kind}" is replaced with the kind
+	}{
+		Kinds:       []string{"email", "phone", "ip", "credit_card", "secret"},
+		Placeholder: "[redacted {kind}]",
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]bool, len(p.Kinds))
+	for _, kind := range p.Kinds {
+		switch kind {
+		case "email", "phone", "ip", "credit_card", "secret":
+			kinds[kind] = true
+		default:
+			return nil, fmt.Errorf("unknown kind %q, expected email, phone, ip, credit_card or secret", kind)
+		}
+	}
+	return PIIFilter{kinds: kinds, placeholder: p.Placeholder}, nil
+}
+
+func (f PIIFilter) Apply(input string, meta Meta) (string, error) {
+	// Secrets first, tokens may contain things looking like other kinds
+	if f.kinds["secret"] {
+		input = redact(input, meta, "secret", f.placeholderFor("secret"), secretPatterns...)
+	}
+	for _, kind := range piiKinds {
+		if !f.kinds[kind.name] {
+			continue
+		}
+		count := 0
+		var b strings.Builder
+		last := 0
+		for _, m := range kind.pattern.FindAllStringIndex(input, -1) {
+			if kind.valid != nil && !kind.valid(input[m[0]:m[1]], input[:m[0]]) {
+				continue
+			}
+			count++
+			b.WriteString(input[last:m[0]])
+			b.WriteString(f.placeholderFor(kind.name))
+			last = m[1]
+		}
+		b.WriteString(input[last:])
+		input = b.String()
+		meta.Record(kind.name, count)
+	}
+	return input, nil
+}
+
+func (f PIIFilter) placeholderFor(kind string) string {
+	return strings.ReplaceAll(f.placeholder, "{kind}", kind)
+}
+
+func (f PIIFilter) Name() string {
+	return "redact_pii"
+}
+
+// redact replaces every match of the patterns and records the count.
+func redact(input string, meta Meta, kind, placeholder string, patterns ...*regexp.Regexp) string {
+	count := 0
+	for _, pattern := range patterns {
+		input = pattern.ReplaceAllStringFunc(input, func(string) string {
+			count++
+			return placeholder
+		})
+	}
+	meta.Record(kind, count)
+	return input
+}
+
+func digitsOf(s string) []int {
+	var digits []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	return digits
+}
+
+// luhnValid checks the Luhn checksum of card numbers.
+func luhnValid(match, _ string) bool {
+	digits := digitsOf(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func ipValid(match, before string) bool {
+	return net.ParseIP(match) != nil && !versionRegex.MatchString(before)
+}
+
+func phoneValid(match, _ string) bool {
+	digits := len(digitsOf(match))
+	return digits >= 7 && digits <= 15
+}
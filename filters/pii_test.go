@@ -0,0 +1,68 @@
+package filters
+
+import "testing"
+
+func TestPIIFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"email", "write to ann@example.com today", "write to [redacted email] today"},
+		{"card", "card 4111 1111 1111 1111 please", "card [redacted credit_card] please"},
+		{"card failing luhn", "order 4111 1111 1111 1112", "order 4111 1111 1111 1112"},
+		{"ipv4", "server at 192.168.1.20", "server at [redacted ip]"},
+		{"ipv6", "server at 2001:db8::1", "server at [redacted ip]"},
+		{"secret", "token sk-abcdefghijklmnopqrstuvwxyz123456", "token [redacted secret]"},
+
+		// Phone numbers in real groupings
+		{"international", "call +1 555 123 4567 now", "call [redacted phone] now"},
+		{"international compact", "call +358401234567", "call [redacted phone]"},
+		{"international area code", "call +44 (20) 7946 0958", "call [redacted phone]"},
+		{"area code", "call (555) 123-4567", "call [redacted phone]"},
+		{"3-3-4", "call 555-123-4567", "call [redacted phone]"},
+		{"3-3-4 dots", "call 555.123.4567", "call [redacted phone]"},
+
+		// Look-alikes that must stay
+		{"counts", "we had 2000 3000 4000 people", "we had 2000 3000 4000 people"},
+		{"year range", "from 1999 to 2024", "from 1999 to 2024"},
+		{"date", "on 2024-01-15", "on 2024-01-15"},
+		{"time", "at 12:30", "at 12:30"},
+		{"version", "version 1.2.3.4", "version 1.2.3.4"},
+		{"short version", "v 10.0.0.1 is out", "v 10.0.0.1 is out"},
+		{"attached version", "v1.2.3.4", "v1.2.3.4"},
+		{"invalid ip", "999.1.1.1", "999.1.1.1"},
+		{"price", "$1,299.99", "$1,299.99"},
+	}
+
+	filter, err := newPIIFilter(Params{})
+	if err != nil {
+		t.Fatalf("newPIIFilter: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filter.Apply(tt.input, Meta{})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPIIFilterKinds(t *testing.T) {
+	filter, err := newPIIFilter(Params{"kinds": []string{"email"}, "placeholder": "<{kind}>"})
+	if err != nil {
+		t.Fatalf("newPIIFilter: %v", err)
+	}
+	got, _ := filter.Apply("ann@example.com at 192.168.1.20", Meta{})
+	if want := "<email> at 192.168.1.20"; got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+
+	if _, err := newPIIFilter(Params{"kinds": []string{"shoe_size"}}); err == nil {
+		t.Error("newPIIFilter accepted an unknown kind")
+	}
+}
@@ -0,0 +1,46 @@
+package filters
+
+import (
+	"errors"
+	"regexp"
+)
+
+// RegexReplaceFilter replaces matches of a user-defined regular expression.
+// The replacement may reference groups with $1 or ${name}.
+type RegexReplaceFilter struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func newRegexReplaceFilter(params Params) (Filter, error) {
+	var p struct {
+		Pattern         string `json:"pattern"`
+		Replacement     string `json:"replacement"`
+		CaseInsensitive bool   `json:"case_insensitive"`
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Pattern == "" {
+		return nil, errors.New("pattern is required")
+	}
+
+	pattern := p.Pattern
+	if p.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return RegexReplaceFilter{pattern: compiled, replacement: p.Replacement}, nil
+}
+
+func (r RegexReplaceFilter) Apply(input string, _ Meta) (string, error) {
+	return r.pattern.ReplaceAllString(input, r.replacement), nil
+}
+
+func (r RegexReplaceFilter) Name() string {
+	return "regex_replace"
+}
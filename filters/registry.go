@@ -1,25 +1,97 @@
 package filters
 
-import "NeighBot/logger"
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+)
 
-var filterRegistry = map[string]Filter{}
+// Factory creates a filter from its parameters, rejecting invalid ones.
+type Factory func(params Params) (Filter, error)
 
-func RegisterFilter(filter Filter) {
-	filterRegistry[filter.Name()] = filter
-	logger.Sugar.Infow("Filter registered", "filter_name", filter.Name())
+// Registry holds the filter factories contexts can use by name.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+	plugins   map[string]bool // Names of filters registered by RegisterExec
+	baseDir   string
+	log       *zap.SugaredLogger
 }
 
-func GetFilter(name string) (Filter, bool) {
-	filter, exists := filterRegistry[name]
+func NewRegistry(log *zap.SugaredLogger) *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+		plugins:   make(map[string]bool),
+		log:       log,
+	}
+}
+
+// SetBaseDir sets the directory relative filter files, like word lists, are
+// resolved against.
+func (r *Registry) SetBaseDir(dir string) {
+	r.baseDir = dir
+}
+
+func (r *Registry) RegisterFilter(name string, factory Factory) {
+	r.mu.Lock()
+	r.factories[name] = factory
+	r.mu.Unlock()
+	r.log.Infow("Filter registered", "filter_name", name)
+}
+
+// Build creates the filter declared by spec.
+func (r *Registry) Build(spec Spec) (Filter, error) {
+	r.mu.RLock()
+	factory, exists := r.factories[spec.Name]
+	r.mu.RUnlock()
 	if !exists {
-		logger.Sugar.Warnw("Filter not found", "filter_name", name)
+		return nil, fmt.Errorf("unknown filter: %s", spec.Name)
 	}
-	return filter, exists
+
+	filter, err := factory(spec.Params)
+	if err != nil {
+		return nil, fmt.Errorf("filter %s: %w", spec.Name, err)
+	}
+	return filter, nil
+}
+
+// BuildManager creates a FilterManager applying the declared filters in order.
+func (r *Registry) BuildManager(specs Specs) (*FilterManager, error) {
+	manager := &FilterManager{}
+	for _, spec := range specs {
+		filter, err := r.Build(spec)
+		if err != nil {
+			return nil, err
+		}
+		manager.AddFilter(filter)
+	}
+	return manager, nil
 }
 
-func InitializeFilters() {
-	RegisterFilter(EmojiFilter{})
-	RegisterFilter(EmphasisFilter{})
-	RegisterFilter(LinkFilter{})
-	logger.Sugar.Info("Filters initialized")
+// RegisterDefaults registers the built-in filters.
+func (r *Registry) RegisterDefaults() {
+	r.RegisterFilter("remove_emojis", newEmojiFilter)
+	r.RegisterFilter("remove_emphasis", noParams(EmphasisFilter{}))
+	r.RegisterFilter("remove_links", newLinkFilter)
+	r.RegisterFilter("regex_replace", newRegexReplaceFilter)
+	r.RegisterFilter("replace", newReplaceFilter)
+	r.RegisterFilter("strip_zero_width", newZeroWidthFilter)
+	r.RegisterFilter("collapse_repetition", newRepetitionFilter)
+	r.RegisterFilter("redact_secrets", newSecretsFilter)
+	r.RegisterFilter("max_length", newLengthFilter)
+	r.RegisterFilter("convert_markdown", newFormatFilter)
+	r.RegisterFilter("mention_safety", newMentionSafetyFilter)
+	r.RegisterFilter("redact_pii", newPIIFilter)
+	r.RegisterFilter("blocklist", r.newBlocklistFilter)
+	r.log.Info("Filters initialized")
+}
+
+// noParams is the factory helper of filters without parameters.
+func noParams(filter Filter) Factory {
+	return func(params Params) (Filter, error) {
+		if err := params.Decode(&struct{}{}); err != nil {
+			return nil, err
+		}
+		return filter, nil
+	}
 }
@@ -0,0 +1,76 @@
+package filters
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+)
+
+func newTestRegistry() *Registry {
+	r := NewRegistry(zap.NewNop().Sugar())
+	r.RegisterDefaults()
+	return r
+}
+
+func TestRegisterExecShadowsBuiltin(t *testing.T) {
+	r := newTestRegistry()
+	if err := r.RegisterExec("blocklist", ExecConfig{Command: []string{"cat"}}); err == nil {
+		t.Error("RegisterExec shadowed a built-in filter")
+	}
+	if _, err := r.Build(Spec{Name: "blocklist", Params: Params{"words": []string{"oats"}}}); err != nil {
+		t.Errorf("built-in filter broken: %v", err)
+	}
+}
+
+func TestContextCannotDeclareExec(t *testing.T) {
+	r := newTestRegistry()
+	if _, err := r.Build(Spec{Name: "exec", Params: Params{"command": []string{"cat"}}}); err == nil {
+		t.Error("context config built an executable not declared as a plugin")
+	}
+}
+
+func TestUnregisterExec(t *testing.T) {
+	r := newTestRegistry()
+	if err := r.RegisterExec("shout", ExecConfig{Command: []string{"cat"}}); err != nil {
+		t.Fatalf("RegisterExec: %v", err)
+	}
+	// Plugins can be replaced
+	if err := r.RegisterExec("shout", ExecConfig{Command: []string{"tr"}}); err != nil {
+		t.Fatalf("RegisterExec again: %v", err)
+	}
+	if _, err := r.Build(Spec{Name: "shout"}); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	r.UnregisterExec("shout")
+	r.UnregisterExec("remove_emojis")
+	if _, err := r.Build(Spec{Name: "shout"}); err == nil {
+		t.Error("unregistered plugin still builds")
+	}
+	if _, err := r.Build(Spec{Name: "remove_emojis"}); err != nil {
+		t.Errorf("UnregisterExec removed a built-in filter: %v", err)
+	}
+	if plugins := r.Plugins(); len(plugins) != 0 {
+		t.Errorf("Plugins = %v, want none", plugins)
+	}
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r := newTestRegistry()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			_ = r.RegisterExec("shout", ExecConfig{Command: []string{"cat"}})
+			r.UnregisterExec("shout")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			_, _ = r.Build(Spec{Name: "remove_emojis"})
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,151 @@
+package filters
+
+import (
+	"NeighBot/markdown"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// RepetitionFilter collapses spam repetition: runs of the same character and
+// the same word or line repeated in a row. Messages shrinking by more than
+// the drop ratio are dropped as spam.
+type RepetitionFilter struct {
+	maxChars  int
+	maxWords  int
+	dropRatio float64
+}
+
+func newRepetitionFilter(params Params) (Filter, error) {
+	p := struct {
+		MaxRepeatChars int     `json:"max_repeat_chars"`
+		MaxRepeatWords int     `json:"max_repeat_words"`
+		DropRatio      float64 `json:"drop_ratio"` // 0 never drops
+	}{
+		MaxRepeatChars: 3,
+		MaxRepeatWords: 3,
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.MaxRepeatChars < 1 || p.MaxRepeatWords < 1 {
+		return nil, fmt.Errorf("max_repeat_chars and max_repeat_words must be at least 1")
+	}
+	if p.DropRatio < 0 || p.DropRatio >= 1 {
+		return nil, fmt.Errorf("drop_ratio must be between 0 and 1")
+	}
+
+	return RepetitionFilter{maxChars: p.MaxRepeatChars, maxWords: p.MaxRepeatWords, dropRatio: p.DropRatio}, nil
+}
+
+func (r RepetitionFilter) Apply(input string, _ Meta) (string, error) {
+	// Code is left as written, only the text around it is collapsed
+	var b, prose strings.Builder
+	flush := func() {
+		collapsed := r.collapseLines(prose.String())
+		collapsed = r.collapseWords(collapsed)
+		for _, token := range markdown.Parse(collapsed) {
+			b.WriteString(r.collapseToken(token))
+		}
+		prose.Reset()
+	}
+	for _, token := range markdown.Parse(input) {
+		if token.Kind == markdown.CodeBlock {
+			flush()
+			b.WriteString(token.Raw)
+		} else {
+			prose.WriteString(token.Raw)
+		}
+	}
+	flush()
+	collapsed := b.String()
+
+	if r.dropRatio > 0 && len(input) > 0 {
+		removed := 1 - float64(len(collapsed))/float64(len(input))
+		if removed > r.dropRatio {
+			return "", Reject(r.Name(), fmt.Sprintf("%.0f%% of the message was repetition", removed*100))
+		}
+	}
+	return collapsed, nil
+}
+
+// collapseToken collapses character runs in the text of a token, leaving
+// inline code and links as they are.
+func (r RepetitionFilter) collapseToken(token markdown.Token) string {
+	switch token.Kind {
+	case markdown.Text:
+		return r.collapseChars(token.Raw)
+	case markdown.Italic, markdown.Bold, markdown.Underline, markdown.Strike, markdown.Spoiler:
+		var b strings.Builder
+		b.WriteString(token.Marker)
+		for _, child := range token.Children {
+			b.WriteString(r.collapseToken(child))
+		}
+		b.WriteString(token.Marker)
+		return b.String()
+	default:
+		return token.Raw
+	}
+}
+
+// collapseChars shortens runs of the same letter or punctuation. Digits and
+// whitespace are left alone, they are meaningful in numbers and indentation.
+func (r RepetitionFilter) collapseChars(input string) string {
+	var b strings.Builder
+	b.Grow(len(input))
+	var prev rune
+	run := 0
+	for _, c := range input {
+		if c == prev {
+			run++
+		} else {
+			prev, run = c, 1
+		}
+		if run <= r.maxChars || !(unicode.IsLetter(c) || unicode.IsPunct(c)) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func (r RepetitionFilter) collapseWords(input string) string {
+	lines := strings.Split(input, "\n")
+	for i, line := range lines {
+		words := strings.Split(line, " ")
+		kept := make([]string, 0, len(words))
+		run := 0
+		for j, word := range words {
+			if j > 0 && word != "" && strings.EqualFold(word, words[j-1]) {
+				run++
+			} else {
+				run = 1
+			}
+			if run <= r.maxWords {
+				kept = append(kept, word)
+			}
+		}
+		lines[i] = strings.Join(kept, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r RepetitionFilter) collapseLines(input string) string {
+	lines := strings.Split(input, "\n")
+	kept := make([]string, 0, len(lines))
+	run := 0
+	for i, line := range lines {
+		if i > 0 && strings.TrimSpace(line) != "" && strings.TrimSpace(line) == strings.TrimSpace(lines[i-1]) {
+			run++
+		} else {
+			run = 1
+		}
+		if run <= r.maxWords {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func (r RepetitionFilter) Name() string {
+	return "collapse_repetition"
+}
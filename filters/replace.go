@@ -0,0 +1,121 @@
+package filters
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReplaceFilter replaces literal words or phrases using a dictionary.
+// Longer entries win over shorter ones sharing a prefix.
+type ReplaceFilter struct {
+	pattern    *regexp.Regexp // Any entry, to find candidates
+	entries    []replaceEntry // Longest first
+	wholeWords bool
+}
+
+// replaceEntry is an entry of the dictionary, matching at the start of the text.
+type replaceEntry struct {
+	pattern *regexp.Regexp
+	to      string
+}
+
+func newReplaceFilter(params Params) (Filter, error) {
+	var p struct {
+		Replacements    map[string]string `json:"replacements"`
+		CaseInsensitive bool              `json:"case_insensitive"`
+		WholeWords      bool              `json:"whole_words"`
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	if len(p.Replacements) == 0 {
+		return nil, errors.New("replacements must not be empty")
+	}
+
+	keys := make([]string, 0, len(p.Replacements))
+	for from := range p.Replacements {
+		if from == "" {
+			return nil, errors.New("replacements must not contain an empty key")
+		}
+		keys = append(keys, from)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	flags := ""
+	if p.CaseInsensitive {
+		flags = "(?i)"
+	}
+	filter := ReplaceFilter{wholeWords: p.WholeWords}
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(key))
+		filter.entries = append(filter.entries, replaceEntry{
+			pattern: regexp.MustCompile(flags + "^" + regexp.QuoteMeta(key)),
+			to:      p.Replacements[key],
+		})
+	}
+	filter.pattern = regexp.MustCompile(flags + "(?:" + strings.Join(quoted, "|") + ")")
+	return filter, nil
+}
+
+func (r ReplaceFilter) Apply(input string, _ Meta) (string, error) {
+	var b strings.Builder
+	last := 0
+	for pos := 0; pos < len(input); {
+		loc := r.pattern.FindStringIndex(input[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[0]
+		end, to, ok := r.matchAt(input, start)
+		if !ok {
+			_, size := utf8.DecodeRuneInString(input[start:])
+			pos = start + size
+			continue
+		}
+		b.WriteString(input[last:start])
+		b.WriteString(to)
+		last, pos = end, end
+	}
+	b.WriteString(input[last:])
+	return b.String(), nil
+}
+
+// matchAt returns the end and replacement of the longest entry matching at
+// start, as a whole word if configured.
+func (r ReplaceFilter) matchAt(input string, start int) (int, string, bool) {
+	for _, entry := range r.entries {
+		loc := entry.pattern.FindStringIndex(input[start:])
+		if loc == nil {
+			continue
+		}
+		end := start + loc[1]
+		if r.wholeWords && splitsWord(input[:start], input[start:end], input[end:]) {
+			continue
+		}
+		return end, entry.to, true
+	}
+	return 0, "", false
+}
+
+// splitsWord reports whether the match continues a word of the text around it.
+func splitsWord(before, match, after string) bool {
+	first, _ := utf8.DecodeRuneInString(match)
+	last, _ := utf8.DecodeLastRuneInString(match)
+	previous, previousSize := utf8.DecodeLastRuneInString(before)
+	next, nextSize := utf8.DecodeRuneInString(after)
+	return (previousSize > 0 && isWordRune(previous) && isWordRune(first)) ||
+		(nextSize > 0 && isWordRune(last) && isWordRune(next))
+}
+
+func (r ReplaceFilter) Name() string {
+	return "replace"
+}
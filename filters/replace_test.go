@@ -0,0 +1,41 @@
+package filters
+
+import "testing"
+
+func TestReplaceFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+		input  string
+		want   string
+	}{
+		{"literal", Params{"replacements": map[string]string{"hay": "straw"}}, "hay and haystack", "straw and strawstack"},
+		{"longest first", Params{"replacements": map[string]string{"foo": "1", "foo bar": "2"}}, "foo bar foo", "2 1"},
+		{"case sensitive", Params{"replacements": map[string]string{"hay": "straw"}}, "Hay hay", "Hay straw"},
+
+		{"whole words", Params{"replacements": map[string]string{"hay": "straw"}, "whole_words": true}, "hay haystack hay.", "straw haystack straw."},
+		{"whole words shorter entry", Params{"replacements": map[string]string{"foo": "1", "foo bar": "2"}, "whole_words": true}, "foo barn", "1 barn"},
+		{"whole words non-ASCII", Params{"replacements": map[string]string{"café": "coffee", "ñu": "gnu"}, "whole_words": true}, "un café, un ñu", "un coffee, un gnu"},
+		{"whole words non-ASCII neighbours", Params{"replacements": map[string]string{"cafe": "coffee"}, "whole_words": true}, "cafeé écafe cafe", "cafeé écafe coffee"},
+		{"whole words punctuation entry", Params{"replacements": map[string]string{"!!": "!"}, "whole_words": true}, "wow!! ok", "wow! ok"},
+
+		{"case insensitive", Params{"replacements": map[string]string{"hay": "straw"}, "case_insensitive": true}, "HAY Hay", "straw straw"},
+		{"case insensitive long s", Params{"replacements": map[string]string{"sun": "moon"}, "case_insensitive": true}, "\u017Fun", "moon"},
+		{"case insensitive kelvin", Params{"replacements": map[string]string{"kelvin": "K"}, "case_insensitive": true}, "\u212Aelvin", "K"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newReplaceFilter(tt.params)
+			if err != nil {
+				t.Fatalf("newReplaceFilter: %v", err)
+			}
+			got, err := filter.Apply(tt.input, Meta{})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package filters
+
+import "regexp"
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b[MN][A-Za-z\d_-]{23,25}\.[A-Za-z\d_-]{6}\.[A-Za-z\d_-]{27,38}\b`), // Discord bot token
+	regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z\d_-]{20,}`),                             // OpenAI / Anthropic style keys
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z\d]{36,}\b`),                                     // GitHub tokens
+	regexp.MustCompile(`\bxox[abpors]-[A-Za-z\d-]{10,}\b`),                                  // Slack tokens
+	regexp.MustCompile(`\bAKIA[A-Z\d]{16}\b`),                                               // AWS access key IDs
+	regexp.MustCompile(`\bAIza[A-Za-z\d_-]{35}\b`),                                          // Google API keys
+}
+
+// SecretsFilter redacts common API key and token formats.
+type SecretsFilter struct {
+	placeholder string
+}
+
+func newSecretsFilter(params Params) (Filter, error) {
+	p := struct {
+		Placeholder string `json:"placeholder"`
+	}{
+		Placeholder: "[redacted]",
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return SecretsFilter{placeholder: p.Placeholder}, nil
+}
+
+func (s SecretsFilter) Apply(input string, meta Meta) (string, error) {
+	return redact(input, meta, "secret", s.placeholder, secretPatterns...), nil
+}
+
+func (s SecretsFilter) Name() string {
+	return "redact_secrets"
+}
@@ -0,0 +1,71 @@
+package filters
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ZeroWidthFilter strips invisible formatting characters used to dodge
+// keyword matching. Zero-width (non-)joiners spell words in scripts like
+// Persian and Hindi, so they are only stripped if configured, and joiners
+// inside emoji sequences are always kept.
+type ZeroWidthFilter struct {
+	joiners bool
+}
+
+func newZeroWidthFilter(params Params) (Filter, error) {
+	var p struct {
+		Joiners bool `json:"joiners"` // Also strip zero-width joiners and non-joiners
+	}
+	if err := params.Decode(&p); err != nil {
+		return nil, err
+	}
+	return ZeroWidthFilter{joiners: p.Joiners}, nil
+}
+
+// isZeroWidth reports whether r is a zero-width space or a bidi control.
+func isZeroWidth(r rune) bool {
+	switch {
+	case r == '\u200B', r == '\u2060', r == '\uFEFF':
+		return true
+	case r == '\u061C', r == '\u200E', r == '\u200F':
+		// Bidi marks
+		return true
+	case r >= '\u202A' && r <= '\u202E', r >= '\u2066' && r <= '\u2069':
+		// Bidi embeddings, overrides and isolates
+		return true
+	}
+	return false
+}
+
+func isJoiner(r rune) bool {
+	return r == '\u200C' || r == '\u200D'
+}
+
+func isPictographic(r rune) bool {
+	return r >= 0x1F000 || unicode.Is(unicode.So, r)
+}
+
+func (z ZeroWidthFilter) Apply(input string, _ Meta) (string, error) {
+	runes := []rune(input)
+	var b strings.Builder
+	b.Grow(len(input))
+	for i, r := range runes {
+		if isZeroWidth(r) || (z.joiners && r == '\u200C') {
+			continue
+		}
+		if z.joiners && r == '\u200D' {
+			joinsEmoji := i > 0 && i+1 < len(runes) &&
+				(isPictographic(runes[i-1]) || runes[i-1] == '\uFE0F') && isPictographic(runes[i+1])
+			if !joinsEmoji {
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+func (z ZeroWidthFilter) Name() string {
+	return "strip_zero_width"
+}
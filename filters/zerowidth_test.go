@@ -0,0 +1,38 @@
+package filters
+
+import "testing"
+
+func TestZeroWidthFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		joiners bool
+		input   string
+		want    string
+	}{
+		{"zero-width space", false, "bad\u200Bword", "badword"},
+		{"word joiner and bom", false, "\uFEFFbad\u2060word", "badword"},
+		{"bidi override", false, "bad\u202Edrow\u202C", "baddrow"},
+		{"bidi isolate", false, "\u2066bad\u2069 word", "bad word"},
+		{"persian non-joiner kept", false, "می\u200Cخواهم", "می\u200Cخواهم"},
+		{"devanagari joiner kept", false, "क्\u200Dष", "क्\u200Dष"},
+		{"persian non-joiner stripped", true, "می\u200Cخواهم", "میخواهم"},
+		{"joiner stripped", true, "bad\u200Dword", "badword"},
+		{"emoji joiner kept", true, "👩\u200D💻", "👩\u200D💻"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newZeroWidthFilter(Params{"joiners": tt.joiners})
+			if err != nil {
+				t.Fatalf("newZeroWidthFilter: %v", err)
+			}
+			got, err := filter.Apply(tt.input, Meta{})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,15 +3,14 @@ module NeighBot
 go 1.23.3
 
 require (
-	github.com/dlclark/regexp2 v1.11.4
+	github.com/bwmarrin/discordgo v0.28.1
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/openai/openai-go v0.1.0-alpha.39
 	go.uber.org/zap v1.27.0
 )
 
 require (
-	github.com/bwmarrin/discordgo v0.28.1 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/tidwall/gjson v1.18.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
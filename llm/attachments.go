@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxAttachmentSize is the largest attachment cached, bigger ones are
+	// only described.
+	MaxAttachmentSize = 20 << 20
+
+	// MaxPromptImages is how many of the latest images are sent to vision
+	// models, older ones are described by placeholders.
+	MaxPromptImages = 4
+)
+
+var attachmentClient = &http.Client{Timeout: 30 * time.Second}
+
+// CacheImage downloads an image attached to a message into the context's
+// attachments directory. The returned part describes the image even if
+// caching failed, along with the error.
+func (m *MemoryStore) CacheImage(contextID, messageID, url, filename, mimeType string) (ContentPart, error) {
+	part := ContentPart{
+		Type:     "image",
+		Filename: filepath.Base(filename),
+		MimeType: mimeType,
+		URL:      url,
+	}
+
+	resp, err := attachmentClient.Get(url)
+	if err != nil {
+		return part, fmt.Errorf("download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return part, fmt.Errorf("download image: %s", resp.Status)
+	}
+	if part.MimeType == "" {
+		part.MimeType = resp.Header.Get("Content-Type")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxAttachmentSize+1))
+	if err != nil {
+		return part, fmt.Errorf("download image: %w", err)
+	}
+	if len(data) > MaxAttachmentSize {
+		return part, errors.New("image too large to cache")
+	}
+
+	path := filepath.Join(contextID, "attachments", messageID+"-"+part.Filename)
+	fullPath := filepath.Join(m.dataDir, path)
+	if err = os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return part, err
+	}
+	if err = os.WriteFile(fullPath, data, 0644); err != nil {
+		return part, err
+	}
+	part.Path = path
+	return part, nil
+}
+
+// LoadImages loads the cached data of the latest MaxPromptImages images in
+// the messages, modifying them in place. Images failing to load stay
+// described by placeholders.
+func (m *MemoryStore) LoadImages(messages []StoredMessage) {
+	remaining := MaxPromptImages
+	for i := len(messages) - 1; i >= 0 && remaining > 0; i-- {
+		parts := messages[i].Parts
+		if len(parts) == 0 {
+			continue
+		}
+		// Copy the parts, so the stored messages are not modified
+		parts = append([]ContentPart(nil), parts...)
+		for j := len(parts) - 1; j >= 0 && remaining > 0; j-- {
+			part := &parts[j]
+			if part.Type != "image" || part.Path == "" || !strings.HasPrefix(part.MimeType, "image/") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(m.dataDir, part.Path))
+			if err != nil {
+				m.log.Warnw("Failed to load cached image", "path", part.Path, "error", err)
+				continue
+			}
+			part.Data = data
+			remaining--
+		}
+		messages[i].Parts = parts
+	}
+}
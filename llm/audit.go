@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"NeighBot/filters"
+	"encoding/json"
+	"maps"
+	"sync"
+)
+
+// Audit counts the redactions made by the filters of a context, by direction
+// and kind, so leaks can be reviewed. It is stored next to the memory.
+type Audit struct {
+	mu     sync.Mutex
+	counts map[filters.Direction]map[string]int
+	dirty  bool
+}
+
+func (a *Audit) Record(direction filters.Direction, kind string, count int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.counts == nil {
+		a.counts = make(map[filters.Direction]map[string]int)
+	}
+	if a.counts[direction] == nil {
+		a.counts[direction] = make(map[string]int)
+	}
+	a.counts[direction][kind] += count
+	a.dirty = true
+}
+
+// Counts returns a copy of the redaction counts.
+func (a *Audit) Counts() map[filters.Direction]map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts := make(map[filters.Direction]map[string]int, len(a.counts))
+	for direction, kinds := range a.counts {
+		counts[direction] = maps.Clone(kinds)
+	}
+	return counts
+}
+
+func (a *Audit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Redactions map[filters.Direction]map[string]int `json:"redactions"`
+	}{a.Counts()})
+}
+
+func (a *Audit) UnmarshalJSON(data []byte) error {
+	var stored struct {
+		Redactions map[filters.Direction]map[string]int `json:"redactions"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.counts = stored.Redactions
+	return nil
+}
+
+// takeDirty reports whether the audit changed since the last call.
+func (a *Audit) takeDirty() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	dirty := a.dirty
+	a.dirty = false
+	return dirty
+}
@@ -2,45 +2,373 @@ package llm
 
 import (
 	"NeighBot/filters"
-	"NeighBot/logger"
+	"NeighBot/triggers"
+	"fmt"
+	"go.uber.org/zap"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type StoredContext struct {
+	mu              sync.RWMutex           // Guards the messages and the configurable fields
 	ID              string                 `json:"id"`
 	Name            string                 `json:"name"`
 	Description     string                 `json:"description"`
 	Messages        []StoredMessage        `json:"-"`
-	Filters         map[string]bool        `json:"filters"`
+	Filters         filters.Specs          `json:"filters"`       // Applied to model output
+	InputFilters    filters.Specs          `json:"input_filters"` // Applied to user input before it reaches memory
 	FilterManager   *filters.FilterManager `json:"-"`
-	AssociatedChats []string               `json:"associated_chats"` // TODO: For now it's discord channel IDs
+	InputManager    *filters.FilterManager `json:"-"`
+	AssociatedChats []string               `json:"associated_chats"` // Chat IDs, optionally prefixed with "<adapter instance>:"
+	Regeneration    RegenerationConfig     `json:"regeneration"`
+	Model           string                 `json:"model,omitempty"`    // Overrides the model of the LLM config
+	Vision          *bool                  `json:"vision,omitempty"`   // Whether the model accepts images, defaults to the LLM config
+	Triggers        *triggers.Rules        `json:"triggers,omitempty"` // Defaults to triggers.DefaultRules
+	ThreadMemory    bool                   `json:"thread_memory"`      // Threads get their own derived context instead of sharing this one
+	Reactions       bool                   `json:"reactions"`          // The model may react to the last message instead of replying
+	Parent          string                 `json:"parent,omitempty"`   // Context this one was derived from
+
+	filterRegistry *filters.Registry
+	matcher        *triggers.Matcher
+	audit          *Audit
+	configDigest   string // Of the config file content last loaded or saved
+	log            *zap.SugaredLogger
+}
+
+const (
+	DefaultMaxRetries = 2
+	DefaultNudge      = "Your previous reply could not be sent (%s). Reply again in plain text."
+	DefaultFallback   = "Sorry, I don't have a good answer to that."
+)
+
+// RegenerationConfig controls what happens when the output filters reject a
+// response or leave nothing of it.
+type RegenerationConfig struct {
+	MaxRetries int    `json:"max_retries"` // 0 for the default, negative disables retrying
+	Nudge      string `json:"nudge"`       // System message added to retries, %s is replaced with the reason
+	Fallback   string `json:"fallback"`    // Canned reply sent once retries are exhausted
+}
+
+// Retries returns how often a rejected response is generated again.
+func (c RegenerationConfig) Retries() int {
+	switch {
+	case c.MaxRetries < 0:
+		return 0
+	case c.MaxRetries == 0:
+		return DefaultMaxRetries
+	default:
+		return c.MaxRetries
+	}
+}
+
+// NudgeMessage returns the system message telling the model why its previous
+// reply was rejected.
+func (c RegenerationConfig) NudgeMessage(reason string) StoredMessage {
+	nudge := c.Nudge
+	if nudge == "" {
+		nudge = DefaultNudge
+	}
+	if strings.Contains(nudge, "%s") {
+		nudge = fmt.Sprintf(nudge, reason)
+	}
+	return StoredMessage{Role: "system", Content: nudge, Timestamp: time.Now()}
+}
+
+func (c RegenerationConfig) FallbackReply() string {
+	if c.Fallback == "" {
+		return DefaultFallback
+	}
+	return c.Fallback
 }
 
 func (ctx *StoredContext) AddMessage(message StoredMessage) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 	ctx.Messages = append(ctx.Messages, message)
 }
 
-func (ctx *StoredContext) ApplyFilters(input string) string {
-	if ctx.FilterManager == nil {
-		ctx.InitializeFilters()
+// PromptMessages returns the messages to generate a response from, leaving
+// out deleted ones.
+func (ctx *StoredContext) PromptMessages() []StoredMessage {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	messages := make([]StoredMessage, 0, len(ctx.Messages))
+	for _, message := range ctx.Messages {
+		if !message.Deleted {
+			messages = append(messages, message)
+		}
 	}
-	return ctx.FilterManager.Apply(input)
+	return messages
 }
 
-func (ctx *StoredContext) InitializeFilters() {
-	ctx.FilterManager = &filters.FilterManager{}
-	for filterName, enabled := range ctx.Filters {
-		if !enabled {
-			continue
+// findMessage returns the index of the message with the native ID, or -1.
+// Responses split into several messages are found by any of their IDs.
+// ctx.mu must be held.
+func (ctx *StoredContext) findMessage(messageID string) int {
+	if messageID == "" {
+		return -1
+	}
+	for i := len(ctx.Messages) - 1; i >= 0; i-- {
+		if ctx.Messages[i].MessageID == messageID || slices.Contains(ctx.Messages[i].ExtraIDs, messageID) {
+			return i
 		}
+	}
+	return -1
+}
+
+// LastMessageID returns the native ID of the latest message stored from the
+// chat, or "" if there is none.
+func (ctx *StoredContext) LastMessageID(chatID string) string {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	for i := len(ctx.Messages) - 1; i >= 0; i-- {
+		if message := ctx.Messages[i]; message.ChatID == chatID && message.MessageID != "" {
+			return message.MessageID
+		}
+	}
+	return ""
+}
+
+// insertMessage inserts a message after the messages sent before or at the
+// same time as it. ctx.mu must be held.
+func (ctx *StoredContext) insertMessage(message StoredMessage) {
+	i := len(ctx.Messages)
+	for i > 0 && ctx.Messages[i-1].Timestamp.After(message.Timestamp) {
+		i--
+	}
+	ctx.Messages = slices.Insert(ctx.Messages, i, message)
+}
+
+// GenerateOptions returns the LLM settings overridden by the context.
+func (ctx *StoredContext) GenerateOptions() GenerateOptions {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return GenerateOptions{Model: ctx.Model, Vision: ctx.Vision}
+}
+
+// RegenerationConfig returns how rejected responses are regenerated.
+func (ctx *StoredContext) RegenerationConfig() RegenerationConfig {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.Regeneration
+}
+
+// HasThreadMemory reports whether threads get their own derived context.
+func (ctx *StoredContext) HasThreadMemory() bool {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.ThreadMemory
+}
+
+// Message returns the stored message with the native ID.
+func (ctx *StoredContext) Message(messageID string) (StoredMessage, bool) {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	if i := ctx.findMessage(messageID); i >= 0 {
+		return ctx.Messages[i], true
+	}
+	return StoredMessage{}, false
+}
+
+// Audit returns the redaction counts of the context's filters.
+func (ctx *StoredContext) Audit() *Audit {
+	return ctx.audit
+}
 
-		filter, exists := filters.GetFilter(filterName)
-		if !exists {
-			logger.Sugar.Warnw("Unknown filter", "filter_name", filterName, "context_id", ctx.ID)
-			continue
+// Participants returns the IDs of the users who wrote in the context, most
+// recent first, at most limit of them.
+func (ctx *StoredContext) Participants(limit int) []string {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	var ids []string
+	for i := len(ctx.Messages) - 1; i >= 0 && len(ids) < limit; i-- {
+		id := ctx.Messages[i].UserID
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
 		}
+	}
+	return ids
+}
 
-		ctx.FilterManager.AddFilter(filter)
+// ApplyFilters runs the output filters on a model response.
+func (ctx *StoredContext) ApplyFilters(input string, meta filters.Meta) (string, error) {
+	meta.ContextID, meta.Direction = ctx.ID, filters.Outbound
+	if ctx.audit != nil {
+		meta.Recorder = ctx.audit
+	}
+	output, _, err := ctx.managers()
+	if err != nil {
+		return "", err
+	}
+	return output.Apply(input, meta)
+}
+
+// ApplyInputFilters runs the input filters on a user message.
+// A *filters.RejectError means the message should be dropped.
+func (ctx *StoredContext) ApplyInputFilters(input string, meta filters.Meta) (string, error) {
+	meta.ContextID, meta.Direction = ctx.ID, filters.Inbound
+	if ctx.audit != nil {
+		meta.Recorder = ctx.audit
+	}
+	_, inbound, err := ctx.managers()
+	if err != nil {
+		return "", err
+	}
+	return inbound.Apply(input, meta)
+}
+
+func (ctx *StoredContext) managers() (*filters.FilterManager, *filters.FilterManager, error) {
+	ctx.mu.RLock()
+	output, inbound := ctx.FilterManager, ctx.InputManager
+	ctx.mu.RUnlock()
+	if output != nil && inbound != nil {
+		return output, inbound, nil
+	}
+
+	if err := ctx.InitializeFilters(); err != nil {
+		return nil, nil, err
+	}
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.FilterManager, ctx.InputManager, nil
+}
+
+// InitializeFilters builds the declared filters, failing on unknown filters
+// or invalid parameters.
+func (ctx *StoredContext) InitializeFilters() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.initializeFiltersLocked()
+}
+
+func (ctx *StoredContext) initializeFiltersLocked() error {
+	if ctx.filterRegistry == nil {
+		ctx.FilterManager = &filters.FilterManager{}
+		ctx.InputManager = &filters.FilterManager{}
+		return nil
+	}
+
+	output, err := ctx.filterRegistry.BuildManager(ctx.Filters)
+	if err != nil {
+		return err
+	}
+	inbound, err := ctx.filterRegistry.BuildManager(ctx.InputFilters)
+	if err != nil {
+		return fmt.Errorf("input filters: %w", err)
+	}
+	ctx.FilterManager = output
+	ctx.InputManager = inbound
+	return nil
+}
+
+func (ctx *StoredContext) initializeTriggersLocked() error {
+	rules := triggers.DefaultRules()
+	if ctx.Triggers != nil {
+		rules = *ctx.Triggers
+	}
+	matcher, err := triggers.NewMatcher(rules, nil)
+	if err != nil {
+		return fmt.Errorf("triggers: %w", err)
+	}
+	ctx.matcher = matcher
+	return nil
+}
+
+// MatchTrigger reports whether the message should get a response and which
+// trigger rule matched.
+func (ctx *StoredContext) MatchTrigger(msg triggers.Message) (triggers.Reason, bool) {
+	ctx.mu.RLock()
+	matcher := ctx.matcher
+	ctx.mu.RUnlock()
+	if matcher == nil {
+		return "", false
+	}
+	return matcher.Match(msg)
+}
+
+// HasChat reports whether the context's associated chats contain the chat
+// ID exactly.
+func (ctx *StoredContext) HasChat(chatID string) bool {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return slices.Contains(ctx.AssociatedChats, chatID)
+}
+
+// ApplyConfig replaces the configurable fields of the context with the ones
+// from other, keeping the stored messages. Changed filters are taken over
+// from other, which must have initialized them.
+// It returns a human-readable summary of the changed fields.
+func (ctx *StoredContext) ApplyConfig(other *StoredContext) []string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.configDigest = other.configDigest
+	diff := ctx.configDiffLocked(other)
+	if len(diff) == 0 {
+		return nil
+	}
+
+	ctx.Name = other.Name
+	ctx.Description = other.Description
+	ctx.AssociatedChats = slices.Clone(other.AssociatedChats)
+	ctx.Regeneration = other.Regeneration
+	ctx.Triggers = other.Triggers
+	ctx.matcher = other.matcher
+	ctx.ThreadMemory = other.ThreadMemory
+	ctx.Reactions = other.Reactions
+	ctx.Model = other.Model
+	ctx.Vision = other.Vision
+	if !ctx.Filters.Equal(other.Filters) {
+		ctx.Filters = other.Filters
+		ctx.FilterManager = other.FilterManager
+	}
+	if !ctx.InputFilters.Equal(other.InputFilters) {
+		ctx.InputFilters = other.InputFilters
+		ctx.InputManager = other.InputManager
+	}
+
+	return diff
+}
+
+func (ctx *StoredContext) configDiffLocked(other *StoredContext) []string {
+	var diff []string
+	if ctx.Name != other.Name {
+		diff = append(diff, fmt.Sprintf("name: %q -> %q", ctx.Name, other.Name))
+	}
+	if ctx.Description != other.Description {
+		diff = append(diff, fmt.Sprintf("description: %q -> %q", ctx.Description, other.Description))
+	}
+	if !slices.Equal(ctx.AssociatedChats, other.AssociatedChats) {
+		diff = append(diff, fmt.Sprintf("associated_chats: %v -> %v", ctx.AssociatedChats, other.AssociatedChats))
+	}
+	if ctx.Regeneration != other.Regeneration {
+		diff = append(diff, fmt.Sprintf("regeneration: %+v -> %+v", ctx.Regeneration, other.Regeneration))
+	}
+	if ctx.Model != other.Model {
+		diff = append(diff, fmt.Sprintf("model: %q -> %q", ctx.Model, other.Model))
+	}
+	if !reflect.DeepEqual(ctx.Vision, other.Vision) {
+		diff = append(diff, "vision: changed")
+	}
+	if ctx.ThreadMemory != other.ThreadMemory {
+		diff = append(diff, fmt.Sprintf("thread_memory: %t -> %t", ctx.ThreadMemory, other.ThreadMemory))
+	}
+	if ctx.Reactions != other.Reactions {
+		diff = append(diff, fmt.Sprintf("reactions: %t -> %t", ctx.Reactions, other.Reactions))
+	}
+	if !reflect.DeepEqual(ctx.Triggers, other.Triggers) {
+		diff = append(diff, "triggers: changed")
+	}
+	if !ctx.Filters.Equal(other.Filters) {
+		diff = append(diff, fmt.Sprintf("filters: %s -> %s", ctx.Filters, other.Filters))
+	}
+	if !ctx.InputFilters.Equal(other.InputFilters) {
+		diff = append(diff, fmt.Sprintf("input_filters: %s -> %s", ctx.InputFilters, other.InputFilters))
 	}
+	return diff
 }
 
 func (ctx *StoredContext) SerializeConfig() map[string]interface{} {
@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"NeighBot/filters"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReactionsPrompt tells the model how to react instead of replying, in
+// contexts with reactions enabled.
+const ReactionsPrompt = "To acknowledge the last message without replying, for example when nothing needs to be said, " +
+	"answer with only [react:<emoji>] with a single emoji, e.g. [react:👍]. It is added as a reaction to the message."
+
+var (
+	reactionRegex = regexp.MustCompile(`^\[react:\s*([^\]\s]+)\s*\]$`)
+
+	// customReactionRegex matches custom emoji as "name:id" or as markup
+	customReactionRegex = regexp.MustCompile(`^(?:<a?:)?(\w{2,32}:\d{17,20})>?$`)
+)
+
+// ParseReaction returns the emoji of a response reacting instead of
+// replying. The emoji is empty if it is neither a single emoji nor a custom
+// emoji, so it cannot be reacted with.
+func ParseReaction(response string) (string, bool) {
+	match := reactionRegex.FindStringSubmatch(strings.TrimSpace(response))
+	if match == nil {
+		return "", false
+	}
+	if filters.IsEmoji(match[1]) {
+		return match[1], true
+	}
+	if custom := customReactionRegex.FindStringSubmatch(match[1]); custom != nil {
+		return custom[1], true
+	}
+	return "", true
+}
+
+// ReactionsMessage returns the system message enabling reactions, or false
+// if the context has them disabled.
+func (ctx *StoredContext) ReactionsMessage() (StoredMessage, bool) {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	if !ctx.Reactions {
+		return StoredMessage{}, false
+	}
+	return StoredMessage{Role: "system", Content: ReactionsPrompt, Timestamp: time.Now()}, true
+}
+
+// SetMessageIDs records the native IDs of the latest response once it was
+// sent, so feedback on it can be found. The first ID is the message's own.
+func (m *MemoryStore) SetMessageIDs(contextID string, messageIDs ...string) error {
+	ctx := m.GetContext(contextID)
+	if ctx == nil || len(messageIDs) == 0 {
+		return nil
+	}
+
+	ctx.mu.Lock()
+	for i := len(ctx.Messages) - 1; i >= 0; i-- {
+		message := &ctx.Messages[i]
+		if message.Role != "assistant" {
+			continue
+		}
+		if message.MessageID != "" {
+			// Already sent
+			break
+		}
+		message.MessageID = messageIDs[0]
+		message.ExtraIDs = slices.Clone(messageIDs[1:])
+		ctx.mu.Unlock()
+		return m.SaveContextMemory(ctx)
+	}
+	ctx.mu.Unlock()
+	return nil
+}
+
+// ReplaceResponse replaces the content of the latest response that was not
+// sent yet, e.g. a reaction that could not be added.
+func (m *MemoryStore) ReplaceResponse(contextID, content string) error {
+	ctx := m.GetContext(contextID)
+	if ctx == nil {
+		return nil
+	}
+
+	ctx.mu.Lock()
+	for i := len(ctx.Messages) - 1; i >= 0; i-- {
+		message := &ctx.Messages[i]
+		if message.Role != "assistant" {
+			continue
+		}
+		if message.MessageID != "" {
+			// Already sent
+			break
+		}
+		message.Content = content
+		ctx.mu.Unlock()
+		return m.SaveContextMemory(ctx)
+	}
+	ctx.mu.Unlock()
+	return nil
+}
+
+// AddFeedback records a user's rating of a response, replacing their
+// previous one. It reports whether the response was found.
+func (m *MemoryStore) AddFeedback(contextID, messageID, userID string, rating int) (bool, error) {
+	return m.updateFeedback(contextID, messageID, func(feedback []Feedback) []Feedback {
+		feedback = slices.DeleteFunc(feedback, func(f Feedback) bool { return f.UserID == userID })
+		return append(feedback, Feedback{UserID: userID, Rating: rating, Timestamp: time.Now()})
+	})
+}
+
+// RemoveFeedback removes a user's rating of a response, if it is the given
+// one. It reports whether the response was found.
+func (m *MemoryStore) RemoveFeedback(contextID, messageID, userID string, rating int) (bool, error) {
+	return m.updateFeedback(contextID, messageID, func(feedback []Feedback) []Feedback {
+		return slices.DeleteFunc(feedback, func(f Feedback) bool { return f.UserID == userID && f.Rating == rating })
+	})
+}
+
+func (m *MemoryStore) updateFeedback(contextID, messageID string, update func([]Feedback) []Feedback) (bool, error) {
+	ctx := m.GetContext(contextID)
+	if ctx == nil {
+		return false, nil
+	}
+
+	ctx.mu.Lock()
+	i := ctx.findMessage(messageID)
+	if i < 0 || ctx.Messages[i].Role != "assistant" || ctx.Messages[i].Deleted {
+		ctx.mu.Unlock()
+		return false, nil
+	}
+	// Updated on a copy, copies of the message returned earlier share the slice
+	ctx.Messages[i].Feedback = update(slices.Clone(ctx.Messages[i].Feedback))
+	ctx.mu.Unlock()
+	return true, m.SaveContextMemory(ctx)
+}
+
+// FeedbackRecord is an exported rated response.
+type FeedbackRecord struct {
+	ContextID   string     `json:"context_id"`
+	ContextName string     `json:"context_name"`
+	MessageID   string     `json:"message_id"`
+	Prompt      string     `json:"prompt"` // Latest user message before the response
+	Response    string     `json:"response"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Up          int        `json:"up"`
+	Down        int        `json:"down"`
+	Feedback    []Feedback `json:"feedback"`
+}
+
+// ExportFeedback writes every rated response of all contexts as JSON lines,
+// for evaluating how contexts' personas are received.
+func (m *MemoryStore) ExportFeedback(w io.Writer) error {
+	contextIDs := m.GetAllContextIDs()
+	slices.Sort(contextIDs)
+
+	encoder := json.NewEncoder(w)
+	for _, contextID := range contextIDs {
+		ctx := m.GetContext(contextID)
+		if ctx == nil {
+			continue
+		}
+
+		ctx.mu.RLock()
+		name := ctx.Name
+		ctx.mu.RUnlock()
+
+		prompt := ""
+		for _, message := range ctx.PromptMessages() {
+			if message.Role == "user" {
+				prompt = message.Content
+			}
+			if message.Role != "assistant" || len(message.Feedback) == 0 {
+				continue
+			}
+
+			record := FeedbackRecord{
+				ContextID:   ctx.ID,
+				ContextName: name,
+				MessageID:   message.MessageID,
+				Prompt:      prompt,
+				Response:    message.Content,
+				Timestamp:   message.Timestamp,
+				Feedback:    message.Feedback,
+			}
+			for _, feedback := range message.Feedback {
+				if feedback.Rating > 0 {
+					record.Up++
+				} else if feedback.Rating < 0 {
+					record.Down++
+				}
+			}
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("export feedback: %w", err)
+			}
+		}
+	}
+	return nil
+}
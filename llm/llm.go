@@ -6,44 +6,98 @@ import (
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 	"strings"
+	"sync"
 )
 
+// Provider generates chat responses from stored messages.
+type Provider interface {
+	GenerateResponse(ctx context.Context, messages []StoredMessage, opts GenerateOptions) (string, error)
+	CountTokens(ctx context.Context, messages []StoredMessage) (int, error)
+}
+
+// GenerateOptions override the provider's settings for a single request,
+// e.g. with the model of a context.
+type GenerateOptions struct {
+	Model  string // Empty for the configured model
+	Vision *bool  // Whether the model accepts images, nil for the configured setting
+}
+
+// SettingsUpdater is implemented by providers whose connection settings can
+// be changed at runtime.
+type SettingsUpdater interface {
+	UpdateSettings(apiKey, endpoint, model string, vision bool)
+}
+
 type OpenAIClient struct {
+	mu       sync.RWMutex
 	client   *openai.Client
 	endpoint string
 	model    string
+	vision   bool
+}
+
+func NewOpenAIClient(apiKey, endpoint, model string, vision bool) *OpenAIClient {
+	o := &OpenAIClient{}
+	o.UpdateSettings(apiKey, endpoint, model, vision)
+	return o
 }
 
-func NewOpenAIClient(apiKey, endpoint, model string) *OpenAIClient {
+// UpdateSettings swaps the connection settings of the client.
+// Requests already in flight keep using the previous settings.
+func (o *OpenAIClient) UpdateSettings(apiKey, endpoint, model string, vision bool) {
 	opts := []option.RequestOption{
 		option.WithAPIKey(apiKey),
 		option.WithBaseURL(endpoint + "/v1/"),
 	}
 	c := openai.NewClient(opts...)
 
-	return &OpenAIClient{
-		client:   c,
-		endpoint: endpoint,
-		model:    model,
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.client = c
+	o.endpoint = endpoint
+	o.model = model
+	o.vision = vision
+}
+
+func (o *OpenAIClient) settings() (*openai.Client, string, string) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.client, o.endpoint, o.model
+}
+
+// resolve applies the request options over the configured model and vision support.
+func (o *OpenAIClient) resolve(opts GenerateOptions) (string, bool) {
+	o.mu.RLock()
+	model, vision := o.model, o.vision
+	o.mu.RUnlock()
+	if opts.Model != "" {
+		model = opts.Model
 	}
+	if opts.Vision != nil {
+		vision = *opts.Vision
+	}
+	return model, vision
 }
 
-func (o *OpenAIClient) GenerateResponse(ctx context.Context, messages []StoredMessage) (string, error) {
+func (o *OpenAIClient) GenerateResponse(ctx context.Context, messages []StoredMessage, opts GenerateOptions) (string, error) {
+	client, _, _ := o.settings()
+	model, vision := o.resolve(opts)
+
 	sysMessage := openai.SystemMessage(
 		NeighBotPrompt + "\n" + strings.ReplaceAll(BotPersonaPrompt, "{{.persona}}", DefaultPersona),
 	)
 
 	converted := []openai.ChatCompletionMessageParamUnion{sysMessage}
 	for _, m := range messages {
-		converted = append(converted, m.ToOpenAIMessage())
+		converted = append(converted, m.ToOpenAIMessage(vision))
 	}
 
 	params := openai.ChatCompletionNewParams{
 		Messages: openai.F(converted),
-		Model:    openai.F(o.model),
+		Model:    openai.F(model),
 	}
 
-	completion, err := o.client.Chat.Completions.New(ctx, params)
+	completion, err := client.Chat.Completions.New(ctx, params)
 	if err != nil {
 		return "", err
 	}
@@ -57,6 +111,8 @@ func (o *OpenAIClient) GenerateResponse(ctx context.Context, messages []StoredMe
 }
 
 func (o *OpenAIClient) CountTokens(ctx context.Context, messages []StoredMessage) (int, error) {
+	client, endpoint, _ := o.settings()
+
 	var concatted strings.Builder
 	for _, m := range messages {
 		concatted.WriteString(m.Content)
@@ -75,7 +131,7 @@ func (o *OpenAIClient) CountTokens(ctx context.Context, messages []StoredMessage
 	}{}
 
 	// POST to /tokenize endpoint to get token count
-	if err := o.client.Post(ctx, o.endpoint+"/tokenize", params, &response); err != nil {
+	if err := client.Post(ctx, endpoint+"/tokenize", params, &response); err != nil {
 		return 0, err
 	}
 
@@ -1,35 +1,70 @@
 package llm
 
 import (
-	"NeighBot/logger"
+	"NeighBot/filters"
+	"NeighBot/utilities"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 type MemoryStore struct {
-	contexts map[string]*StoredContext
-	dataDir  string
+	mu             sync.RWMutex
+	contexts       map[string]*StoredContext
+	dataDir        string
+	filterRegistry *filters.Registry
+	log            *zap.SugaredLogger
 }
 
-func NewMemoryStore(dataDir string) *MemoryStore {
+func NewMemoryStore(dataDir string, filterRegistry *filters.Registry, log *zap.SugaredLogger) *MemoryStore {
 	return &MemoryStore{
-		contexts: make(map[string]*StoredContext),
-		dataDir:  dataDir,
+		contexts:       make(map[string]*StoredContext),
+		dataDir:        dataDir,
+		filterRegistry: filterRegistry,
+		log:            log,
+	}
+}
+
+// attach hands the store's shared dependencies to a context entering the
+// store and builds its filters and triggers.
+func (m *MemoryStore) attach(ctx *StoredContext) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.filterRegistry = m.filterRegistry
+	ctx.log = m.log
+	if ctx.audit == nil {
+		ctx.audit = &Audit{}
+	}
+	if err := ctx.initializeTriggersLocked(); err != nil {
+		return err
+	}
+	return ctx.initializeFiltersLocked()
+}
+
+// DefaultFilters returns the filters of newly created contexts.
+func DefaultFilters() filters.Specs {
+	return filters.Specs{
+		{Name: "remove_emojis"},
+		{Name: "remove_emphasis"},
+		{Name: "remove_links", Params: filters.Params{"allow": []string{"dathorse.com"}}},
+		{Name: "mention_safety"},
 	}
 }
 
 func (m *MemoryStore) CreateContext(contextID string) *StoredContext {
 	newContext := &StoredContext{
-		ID:          contextID,
-		Name:        "New Chat",
-		Description: "New context for testing.",
-		Filters: map[string]bool{
-			"remove_emojis":   true,
-			"remove_emphasis": true,
-			"remove_links":    true,
-		},
+		ID:              contextID,
+		Name:            "New Chat",
+		Description:     "New context for testing.",
+		Filters:         DefaultFilters(),
+		InputFilters:    filters.Specs{},
 		AssociatedChats: []string{},
 	}
 
@@ -37,24 +72,62 @@ func (m *MemoryStore) CreateContext(contextID string) *StoredContext {
 	return newContext
 }
 
+// DeriveContext creates a context with the settings of parent, e.g. for a
+// thread, starting with the seed messages. An existing context with the ID
+// is returned as-is.
+func (m *MemoryStore) DeriveContext(parent *StoredContext, contextID, name string, chats []string, seed []StoredMessage) *StoredContext {
+	if ctx := m.GetContext(contextID); ctx != nil {
+		return ctx
+	}
+
+	parent.mu.RLock()
+	ctx := &StoredContext{
+		ID:              contextID,
+		Name:            name,
+		Description:     parent.Description,
+		Messages:        seed,
+		Filters:         parent.Filters,
+		InputFilters:    parent.InputFilters,
+		AssociatedChats: chats,
+		Regeneration:    parent.Regeneration,
+		Model:           parent.Model,
+		Vision:          parent.Vision,
+		Triggers:        parent.Triggers,
+		Reactions:       parent.Reactions,
+		Parent:          parent.ID,
+	}
+	parent.mu.RUnlock()
+
+	m.AddContext(ctx)
+	return m.GetContext(contextID)
+}
+
 func (m *MemoryStore) AddContext(ctx *StoredContext) {
+	m.mu.Lock()
 	if _, exists := m.contexts[ctx.ID]; exists {
-		logger.Sugar.Warnw("Context already exists, skipping addition", "context_id", ctx.ID)
+		m.mu.Unlock()
+		m.log.Warnw("Context already exists, skipping addition", "context_id", ctx.ID)
 		return
 	}
 
+	if err := m.attach(ctx); err != nil {
+		m.log.Errorw("Invalid filters in added context", "context_id", ctx.ID, "error", err)
+	}
 	m.contexts[ctx.ID] = ctx
-	logger.Sugar.Infow("Context added to MemoryStore", "context_id", ctx.ID)
+	m.mu.Unlock()
+	m.log.Infow("Context added to MemoryStore", "context_id", ctx.ID)
 
 	if err := m.SaveContextConfig(ctx); err != nil {
-		logger.Sugar.Errorw("Failed to save context config during AddContext", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to save context config during AddContext", "context_id", ctx.ID, "error", err)
 	}
 	if err := m.SaveContextMemory(ctx); err != nil {
-		logger.Sugar.Errorw("Failed to save context memory during AddContext", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to save context memory during AddContext", "context_id", ctx.ID, "error", err)
 	}
 }
 
 func (m *MemoryStore) GetAllContextIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	contextIDs := make([]string, 0, len(m.contexts))
 	for contextID := range m.contexts {
 		contextIDs = append(contextIDs, contextID)
@@ -63,16 +136,24 @@ func (m *MemoryStore) GetAllContextIDs() []string {
 }
 
 func (m *MemoryStore) GetContext(contextID string) *StoredContext {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	if ctx, exists := m.contexts[contextID]; exists {
 		return ctx
 	}
 	return nil
 }
 
-func (m *MemoryStore) GetContextForChat(chatID string) *StoredContext {
-	for _, ctx := range m.contexts {
-		for _, chat := range ctx.AssociatedChats {
-			if chat == chatID {
+// GetContextForChat returns the context associated with a chat of an adapter
+// instance. Chats are associated either qualified as "<adapter instance>:<chat
+// ID>", matching only that instance, or as bare chat IDs matching every
+// instance. Qualified associations take precedence.
+func (m *MemoryStore) GetContextForChat(instanceName, chatID string) *StoredContext {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range []string{instanceName + ":" + chatID, chatID} {
+		for _, ctx := range m.contexts {
+			if ctx.HasChat(key) {
 				return ctx
 			}
 		}
@@ -80,72 +161,100 @@ func (m *MemoryStore) GetContextForChat(chatID string) *StoredContext {
 	return nil
 }
 
+// ChatsForInstance returns the chats associated with contexts that an adapter
+// instance handles: those qualified with its name and unqualified ones.
+func (m *MemoryStore) ChatsForInstance(instanceName string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var chats []string
+	for _, ctx := range m.contexts {
+		ctx.mu.RLock()
+		for _, chat := range ctx.AssociatedChats {
+			if chatID, ok := strings.CutPrefix(chat, instanceName+":"); ok {
+				chats = append(chats, chatID)
+			} else if !strings.Contains(chat, ":") {
+				chats = append(chats, chat)
+			}
+		}
+		ctx.mu.RUnlock()
+	}
+	slices.Sort(chats)
+	return slices.Compact(chats)
+}
+
 func (m *MemoryStore) SaveContextConfig(ctx *StoredContext) error {
+	ctx.mu.RLock()
 	data, err := json.MarshalIndent(ctx, "", "  ")
+	ctx.mu.RUnlock()
 	if err != nil {
-		logger.Sugar.Errorw("Failed to marshal context config for saving", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to marshal context config for saving", "context_id", ctx.ID, "error", err)
 		return err
 	}
 
 	path := filepath.Join(m.dataDir, ctx.ID, "config.json")
 	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		logger.Sugar.Errorw("Failed to create directory for context config", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to create directory for context config", "context_id", ctx.ID, "error", err)
 		return err
 	}
 
 	if err = os.WriteFile(path, data, 0644); err != nil {
-		logger.Sugar.Errorw("Failed to save context config to file", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to save context config to file", "context_id", ctx.ID, "error", err)
 		return err
 	}
+	ctx.mu.Lock()
+	ctx.configDigest = utilities.Digest(data)
+	ctx.mu.Unlock()
 
-	logger.Sugar.Infow("Successfully saved context config", "context_id", ctx.ID)
+	m.log.Infow("Successfully saved context config", "context_id", ctx.ID)
 	return nil
 }
 
 func (m *MemoryStore) SaveContextMemory(ctx *StoredContext) error {
+	ctx.mu.RLock()
 	memoryData := map[string]interface{}{
 		"messages": ctx.Messages,
 	}
-
 	data, err := json.MarshalIndent(memoryData, "", "  ")
+	ctx.mu.RUnlock()
 	if err != nil {
-		logger.Sugar.Errorw("Failed to marshal context memory for saving", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to marshal context memory for saving", "context_id", ctx.ID, "error", err)
 		return err
 	}
 
 	path := filepath.Join(m.dataDir, ctx.ID, "memory.json")
 	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		logger.Sugar.Errorw("Failed to create directory for context memory", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to create directory for context memory", "context_id", ctx.ID, "error", err)
 		return err
 	}
 
 	if err = os.WriteFile(path, data, 0644); err != nil {
-		logger.Sugar.Errorw("Failed to save context memory to file", "context_id", ctx.ID, "error", err)
+		m.log.Errorw("Failed to save context memory to file", "context_id", ctx.ID, "error", err)
 		return err
 	}
 
-	logger.Sugar.Infow("Successfully saved context memory", "context_id", ctx.ID)
+	m.log.Infow("Successfully saved context memory", "context_id", ctx.ID)
 	return nil
 }
 
 func (m *MemoryStore) LoadContextConfig(contextID string) (*StoredContext, error) {
 	path := filepath.Join(m.dataDir, contextID, "config.json")
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		logger.Sugar.Warnw("Context config file does not exist", "context_id", contextID)
+		m.log.Warnw("Context config file does not exist", "context_id", contextID)
 		return nil, nil
 	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		logger.Sugar.Errorw("Failed to read context config file", "context_id", contextID, "error", err)
+		m.log.Errorw("Failed to read context config file", "context_id", contextID, "error", err)
 		return nil, err
 	}
 
 	context := &StoredContext{ID: contextID}
 	if err = json.Unmarshal(data, &context); err != nil {
-		logger.Sugar.Errorw("Failed to unmarshal context config file", "context_id", contextID, "error", err)
+		m.log.Errorw("Failed to unmarshal context config file", "context_id", contextID, "error", err)
 		return nil, err
 	}
+	context.configDigest = utilities.Digest(data)
 
 	return context, nil
 }
@@ -153,13 +262,13 @@ func (m *MemoryStore) LoadContextConfig(contextID string) (*StoredContext, error
 func (m *MemoryStore) LoadContextMemory(contextID string, ctx *StoredContext) error {
 	path := filepath.Join(m.dataDir, contextID, "memory.json")
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		logger.Sugar.Warnw("Context memory file does not exist", "context_id", contextID)
+		m.log.Warnw("Context memory file does not exist", "context_id", contextID)
 		return nil
 	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
-		logger.Sugar.Errorw("Failed to read context memory file", "context_id", contextID, "error", err)
+		m.log.Errorw("Failed to read context memory file", "context_id", contextID, "error", err)
 		return err
 	}
 
@@ -167,12 +276,54 @@ func (m *MemoryStore) LoadContextMemory(contextID string, ctx *StoredContext) er
 		Messages []StoredMessage `json:"messages"`
 	}
 	if err = json.Unmarshal(data, &memoryData); err != nil {
-		logger.Sugar.Errorw("Failed to unmarshal context memory file", "context_id", contextID, "error", err)
+		m.log.Errorw("Failed to unmarshal context memory file", "context_id", contextID, "error", err)
 		return err
 	}
 
+	ctx.mu.Lock()
 	ctx.Messages = memoryData.Messages
-	logger.Sugar.Infow("Successfully loaded context memory", "context_id", contextID)
+	ctx.mu.Unlock()
+	m.log.Infow("Successfully loaded context memory", "context_id", contextID)
+	return nil
+}
+
+// SaveContextAudit writes the redaction counts of the context, if they changed.
+func (m *MemoryStore) SaveContextAudit(ctx *StoredContext) error {
+	if ctx.audit == nil || !ctx.audit.takeDirty() {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(ctx.audit, "", "  ")
+	if err != nil {
+		m.log.Errorw("Failed to marshal context audit for saving", "context_id", ctx.ID, "error", err)
+		return err
+	}
+
+	path := filepath.Join(m.dataDir, ctx.ID, "audit.json")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		m.log.Errorw("Failed to save context audit to file", "context_id", ctx.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *MemoryStore) LoadContextAudit(contextID string, ctx *StoredContext) error {
+	path := filepath.Join(m.dataDir, contextID, "audit.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		m.log.Errorw("Failed to read context audit file", "context_id", contextID, "error", err)
+		return err
+	}
+
+	audit := &Audit{}
+	if err = json.Unmarshal(data, audit); err != nil {
+		m.log.Errorw("Failed to unmarshal context audit file", "context_id", contextID, "error", err)
+		return err
+	}
+	ctx.audit = audit
 	return nil
 }
 
@@ -180,7 +331,7 @@ func (m *MemoryStore) LoadAllContexts() error {
 	dir := filepath.Join(m.dataDir)
 	files, err := os.ReadDir(dir)
 	if err != nil && !os.IsNotExist(err) {
-		logger.Sugar.Errorw("Failed to read contexts directory", "path", dir, "error", err)
+		m.log.Errorw("Failed to read contexts directory", "path", dir, "error", err)
 		return err
 	}
 
@@ -198,35 +349,127 @@ func (m *MemoryStore) LoadAllContexts() error {
 			if err = m.LoadContextMemory(contextID, ctx); err != nil {
 				return err
 			}
+			if err = m.LoadContextAudit(contextID, ctx); err != nil {
+				return err
+			}
 
+			if err = m.attach(ctx); err != nil {
+				m.log.Errorw("Invalid filters in context config", "context_id", contextID, "error", err)
+				return err
+			}
+			m.mu.Lock()
 			m.contexts[contextID] = ctx
+			m.mu.Unlock()
 		}
 	}
 
-	logger.Sugar.Infow("Successfully loaded all contexts")
+	m.log.Infow("Successfully loaded all contexts")
 	return nil
 }
 
 func (m *MemoryStore) SaveAllContexts() error {
+	m.mu.RLock()
+	contexts := make(map[string]*StoredContext, len(m.contexts))
 	for contextID, ctx := range m.contexts {
-		if err := m.SaveContextConfig(ctx); err != nil {
-			logger.Sugar.Errorw("Failed to save context config", "context_id", contextID, "error", err)
+		contexts[contextID] = ctx
+	}
+	m.mu.RUnlock()
+
+	for contextID, ctx := range contexts {
+		// Edits not applied, e.g. unparsable or with reloading disabled, are kept
+		changed, err := m.configChangedOnDisk(ctx)
+		if err != nil {
+			return err
+		}
+		if changed {
+			m.log.Warnw("Context config file changed since it was applied, not saving", "context_id", contextID)
+		} else if err = m.SaveContextConfig(ctx); err != nil {
+			m.log.Errorw("Failed to save context config", "context_id", contextID, "error", err)
 			return err
 		}
 		if err := m.SaveContextMemory(ctx); err != nil {
-			logger.Sugar.Errorw("Failed to save context memory", "context_id", contextID, "error", err)
+			m.log.Errorw("Failed to save context memory", "context_id", contextID, "error", err)
+			return err
+		}
+		if err := m.SaveContextAudit(ctx); err != nil {
 			return err
 		}
 	}
-	logger.Sugar.Infow("Successfully saved all contexts")
+	m.log.Infow("Successfully saved all contexts")
 	return nil
 }
 
+// configChangedOnDisk reports whether the config file of a context differs
+// from the content last loaded or saved.
+func (m *MemoryStore) configChangedOnDisk(ctx *StoredContext) (bool, error) {
+	digest, err := utilities.FileDigest(filepath.Join(m.dataDir, ctx.ID, "config.json"))
+	if err != nil {
+		return false, err
+	}
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return digest != ctx.configDigest, nil
+}
+
+// ReloadContextConfig re-reads the config file of a context from disk and
+// applies it to the loaded context, keeping its messages. Contexts that were
+// not loaded yet are loaded along with their memory. It returns a summary of
+// the changed fields.
+func (m *MemoryStore) ReloadContextConfig(contextID string) ([]string, error) {
+	fresh, err := m.LoadContextConfig(contextID)
+	if err != nil {
+		return nil, err
+	}
+	if fresh == nil {
+		return nil, nil
+	}
+
+	if err = m.attach(fresh); err != nil {
+		return nil, err
+	}
+
+	existing := m.GetContext(contextID)
+	if existing == nil {
+		if err = m.LoadContextMemory(contextID, fresh); err != nil {
+			return nil, err
+		}
+		if err = m.LoadContextAudit(contextID, fresh); err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		m.contexts[contextID] = fresh
+		m.mu.Unlock()
+		return []string{"context added"}, nil
+	}
+
+	return existing.ApplyConfig(fresh), nil
+}
+
+// RebuildFilters builds the filters of every context again, picking up
+// changed filter registrations. Contexts failing to build keep their filters.
+func (m *MemoryStore) RebuildFilters() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var errs []error
+	for contextID, ctx := range m.contexts {
+		if err := ctx.InitializeFilters(); err != nil {
+			errs = append(errs, fmt.Errorf("context %s: %w", contextID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ContextConfigPattern returns a glob pattern matching every context config file.
+func (m *MemoryStore) ContextConfigPattern() string {
+	return filepath.Join(m.dataDir, "*", "config.json")
+}
+
 func (m *MemoryStore) PopulateEmptyFolders() error {
 	dir := filepath.Join(m.dataDir)
 	files, err := os.ReadDir(dir)
 	if err != nil && !os.IsNotExist(err) {
-		logger.Sugar.Errorw("Failed to read contexts directory", "path", dir, "error", err)
+		m.log.Errorw("Failed to read contexts directory", "path", dir, "error", err)
 		return err
 	}
 
@@ -238,25 +481,22 @@ func (m *MemoryStore) PopulateEmptyFolders() error {
 
 			if _, err = os.Stat(configPath); os.IsNotExist(err) {
 				configData := map[string]interface{}{
-					"context_id":  contextID,
-					"name":        "New Chat",
-					"description": "New context for testing.",
-					"filters": map[string]bool{
-						"remove_emojis":   true,
-						"remove_emphasis": true,
-						"remove_links":    true,
-					},
+					"context_id":       contextID,
+					"name":             "New Chat",
+					"description":      "New context for testing.",
+					"filters":          DefaultFilters(),
+					"input_filters":    filters.Specs{},
 					"associated_chats": []string{},
 				}
 
 				data, err := json.MarshalIndent(configData, "", "  ")
 				if err != nil {
-					logger.Sugar.Errorw("Failed to marshal context config for saving", "context_id", contextID, "error", err)
+					m.log.Errorw("Failed to marshal context config for saving", "context_id", contextID, "error", err)
 					return err
 				}
 
 				if err = os.WriteFile(configPath, data, 0644); err != nil {
-					logger.Sugar.Errorw("Failed to save context config to file", "context_id", contextID, "error", err)
+					m.log.Errorw("Failed to save context config to file", "context_id", contextID, "error", err)
 					return err
 				}
 			}
@@ -268,44 +508,137 @@ func (m *MemoryStore) PopulateEmptyFolders() error {
 
 				data, err := json.MarshalIndent(memoryData, "", "  ")
 				if err != nil {
-					logger.Sugar.Errorw("Failed to marshal context memory for saving", "context_id", contextID, "error", err)
+					m.log.Errorw("Failed to marshal context memory for saving", "context_id", contextID, "error", err)
 					return err
 				}
 
 				if err = os.WriteFile(memoryPath, data, 0644); err != nil {
-					logger.Sugar.Errorw("Failed to save context memory to file", "context_id", contextID, "error", err)
+					m.log.Errorw("Failed to save context memory to file", "context_id", contextID, "error", err)
 					return err
 				}
 			}
 		}
 	}
 
-	logger.Sugar.Infow("Successfully populated empty folders")
+	m.log.Infow("Successfully populated empty folders")
 	return nil
 }
 
-func (m *MemoryStore) AddUserMessage(contextID, source, username, content string) error {
+// AddUserMessage stores a user message, filling in its role and timestamp.
+func (m *MemoryStore) AddUserMessage(contextID string, message StoredMessage) error {
 	ctx := m.GetContext(contextID)
 	if ctx == nil {
-		logger.Sugar.Warnw("Context does not exist", "context_id", contextID)
+		m.log.Warnw("Context does not exist", "context_id", contextID)
 		return nil
 	}
 
-	userMessage := StoredMessage{
-		Username:  username,
-		Source:    source,
-		Role:      "user",
-		Content:   content,
-		Timestamp: time.Now(),
+	message.Role = "user"
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+	ctx.AddMessage(message)
+	if err := m.SaveContextMemory(ctx); err != nil {
+		return err
 	}
-	ctx.AddMessage(userMessage)
-	return m.SaveContextMemory(ctx)
+	return m.SaveContextAudit(ctx)
+}
+
+// InsertMessages stores user messages received while NeighBot was away,
+// ordered by timestamp among the stored ones. Messages already stored are
+// skipped. It returns how many were inserted.
+func (m *MemoryStore) InsertMessages(contextID string, messages []StoredMessage) (int, error) {
+	ctx := m.GetContext(contextID)
+	if ctx == nil {
+		m.log.Warnw("Context does not exist", "context_id", contextID)
+		return 0, nil
+	}
+
+	ctx.mu.Lock()
+	inserted := 0
+	for _, message := range messages {
+		if ctx.findMessage(message.MessageID) >= 0 {
+			continue
+		}
+		message.Role = "user"
+		if message.Timestamp.IsZero() {
+			message.Timestamp = time.Now()
+		}
+		ctx.insertMessage(message)
+		inserted++
+	}
+	ctx.mu.Unlock()
+	if inserted == 0 {
+		return 0, nil
+	}
+	if err := m.SaveContextMemory(ctx); err != nil {
+		return inserted, err
+	}
+	return inserted, m.SaveContextAudit(ctx)
+}
+
+// UpdateMessage replaces the content of an edited message. It reports
+// whether the message was found.
+func (m *MemoryStore) UpdateMessage(contextID, messageID, content string) (bool, error) {
+	ctx := m.GetContext(contextID)
+	if ctx == nil {
+		return false, nil
+	}
+
+	ctx.mu.Lock()
+	i := ctx.findMessage(messageID)
+	if i < 0 || ctx.Messages[i].Deleted {
+		ctx.mu.Unlock()
+		return false, nil
+	}
+	editedAt := time.Now()
+	ctx.Messages[i].Content = content
+	ctx.Messages[i].EditedAt = &editedAt
+	ctx.mu.Unlock()
+	return true, m.SaveContextMemory(ctx)
+}
+
+// DeleteMessages tombstones deleted messages, clearing their content and
+// attachments. It returns how many were found.
+func (m *MemoryStore) DeleteMessages(contextID string, messageIDs ...string) (int, error) {
+	ctx := m.GetContext(contextID)
+	if ctx == nil {
+		return 0, nil
+	}
+
+	ctx.mu.Lock()
+	deleted := 0
+	var attachments []string
+	for _, messageID := range messageIDs {
+		if i := ctx.findMessage(messageID); i >= 0 && !ctx.Messages[i].Deleted {
+			for _, part := range ctx.Messages[i].Parts {
+				if part.Path != "" {
+					attachments = append(attachments, part.Path)
+				}
+			}
+			ctx.Messages[i].Content = ""
+			ctx.Messages[i].Parts = nil
+			ctx.Messages[i].Deleted = true
+			deleted++
+		}
+	}
+	ctx.mu.Unlock()
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	// Remove the cached attachments too
+	for _, path := range attachments {
+		if err := os.Remove(filepath.Join(m.dataDir, path)); err != nil && !os.IsNotExist(err) {
+			m.log.Warnw("Failed to remove cached attachment", "context_id", contextID, "path", path, "error", err)
+		}
+	}
+	return deleted, m.SaveContextMemory(ctx)
 }
 
 func (m *MemoryStore) AddAssistantMessage(contextID, source, content string) error {
 	ctx := m.GetContext(contextID)
 	if ctx == nil {
-		logger.Sugar.Warnw("Context does not exist", "context_id", contextID)
+		m.log.Warnw("Context does not exist", "context_id", contextID)
 		return nil
 	}
 
@@ -317,15 +650,8 @@ func (m *MemoryStore) AddAssistantMessage(contextID, source, content string) err
 		Timestamp: time.Now(),
 	}
 	ctx.AddMessage(assistantMessage)
-	return m.SaveContextMemory(ctx)
-}
-
-func (m *MemoryStore) ApplyFilters(contextID, content string) string {
-	ctx := m.GetContext(contextID)
-	if ctx == nil {
-		logger.Sugar.Warnw("Context does not exist", "context_id", contextID)
-		return content
+	if err := m.SaveContextMemory(ctx); err != nil {
+		return err
 	}
-
-	return ctx.ApplyFilters(content)
+	return m.SaveContextAudit(ctx)
 }
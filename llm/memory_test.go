@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentMessageAccess is meant to run with -race.
+func TestConcurrentMessageAccess(t *testing.T) {
+	store := NewMemoryStore(t.TempDir(), nil, zap.NewNop().Sugar())
+	store.AddContext(&StoredContext{ID: "stable"})
+	if err := store.AddUserMessage("stable", StoredMessage{MessageID: "m0", Content: "hi"}); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if err := store.AddAssistantMessage("stable", "test", "hay"); err != nil {
+		t.Fatalf("AddAssistantMessage: %v", err)
+	}
+	if err := store.SetMessageIDs("stable", "r0"); err != nil {
+		t.Fatalf("SetMessageIDs: %v", err)
+	}
+	for i := range 20 {
+		if err := store.AddUserMessage("stable", StoredMessage{MessageID: fmt.Sprintf("d%d", i), Content: "bye"}); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 20 {
+				f(i)
+			}
+		}()
+	}
+	run(func(i int) {
+		_ = store.AddUserMessage("stable", StoredMessage{MessageID: fmt.Sprintf("a%d", i), Content: "more"})
+	})
+	run(func(i int) {
+		old := StoredMessage{MessageID: fmt.Sprintf("b%d", i), Timestamp: time.Now().Add(-time.Hour)}
+		_, _ = store.InsertMessages("stable", []StoredMessage{old})
+	})
+	run(func(int) { _, _ = store.UpdateMessage("stable", "m0", "edited") })
+	run(func(int) { _, _ = store.AddFeedback("stable", "r0", "u1", 1) })
+	run(func(i int) { _, _ = store.DeleteMessages("stable", fmt.Sprintf("d%d", i)) })
+	run(func(int) {
+		ctx := store.GetContext("stable")
+		ctx.PromptMessages()
+		ctx.Participants(5)
+		ctx.Message("m0")
+	})
+	wg.Wait()
+
+	ctx := store.GetContext("stable")
+	if message, ok := ctx.Message("m0"); !ok || message.Content != "edited" {
+		t.Errorf("Message(m0) = %+v, %t, want the edited message", message, ok)
+	}
+	if got := len(ctx.PromptMessages()); got != 2+20+20 {
+		t.Errorf("got %d messages left, want %d", got, 2+20+20)
+	}
+}
+
+func TestDeleteMessagesRemovesAttachments(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMemoryStore(dir, nil, zap.NewNop().Sugar())
+	store.AddContext(&StoredContext{ID: "ctx"})
+
+	path := filepath.Join("ctx", "attachments", "m1-hay.png")
+	if err := os.MkdirAll(filepath.Join(dir, "ctx", "attachments"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, path), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	message := StoredMessage{
+		MessageID: "m1",
+		Content:   "look",
+		Parts:     []ContentPart{{Type: "image", Filename: "hay.png", MimeType: "image/png", Path: path}},
+	}
+	if err := store.AddUserMessage("ctx", message); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+
+	if deleted, err := store.DeleteMessages("ctx", "m1"); err != nil || deleted != 1 {
+		t.Fatalf("DeleteMessages = %d, %v, want 1, nil", deleted, err)
+	}
+	got, ok := store.GetContext("ctx").Message("m1")
+	if !ok || !got.Deleted || got.Content != "" || got.Parts != nil {
+		t.Errorf("Message(m1) = %+v, %t, want a tombstone without parts", got, ok)
+	}
+	if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+		t.Errorf("cached attachment still exists: %v", err)
+	}
+}
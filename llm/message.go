@@ -1,23 +1,77 @@
 package llm
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/openai/openai-go"
+	"strings"
 	"time"
 )
 
+// ContentPart is a non-text part of a message, like an attached image.
+type ContentPart struct {
+	Type     string `json:"type"` // Only "image" for now
+	Filename string `json:"filename"`
+	MimeType string `json:"mime_type,omitempty"`
+	URL      string `json:"url,omitempty"`  // Where the part was downloaded from
+	Path     string `json:"path,omitempty"` // Cached copy, relative to the data directory
+
+	Data []byte `json:"-"` // Cached content, loaded only for prompts
+}
+
+func (p ContentPart) dataURL() string {
+	return "data:" + p.MimeType + ";base64," + base64.StdEncoding.EncodeToString(p.Data)
+}
+
+func (p ContentPart) placeholder() string {
+	return fmt.Sprintf("[%s: %s]", p.Type, p.Filename)
+}
+
 type StoredMessage struct {
-	Username  string    `json:"username"`
-	Source    string    `json:"source"`
-	Role      string    `json:"role"`
-	Content   string    `json:"content"`
+	MessageID string        `json:"message_id,omitempty"`  // Adapter's native message ID
+	ChatID    string        `json:"chat_id,omitempty"`     // Adapter's native chat ID, to resume history from
+	ReplyToID string        `json:"reply_to_id,omitempty"` // Native ID of the message this one replies to
+	UserID    string        `json:"user_id,omitempty"`     // Platform user ID, used to allow pinging the user
+	Username  string        `json:"username"`
+	Source    string        `json:"source"`
+	Role      string        `json:"role"`
+	Content   string        `json:"content"`
+	Parts     []ContentPart `json:"parts,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	EditedAt  *time.Time    `json:"edited_at,omitempty"`
+	Deleted   bool          `json:"deleted,omitempty"`   // Tombstone of a message deleted on the platform, left out of prompts
+	ExtraIDs  []string      `json:"extra_ids,omitempty"` // Native IDs of further messages a long response was split into
+	Feedback  []Feedback    `json:"feedback,omitempty"`  // Ratings of a response by users
+}
+
+// Feedback is a user's rating of a response, given by reacting to it.
+type Feedback struct {
+	UserID    string    `json:"user_id"`
+	Rating    int       `json:"rating"` // 1 for good, -1 for bad
 	Timestamp time.Time `json:"timestamp"`
 }
 
-func (sm StoredMessage) ToOpenAIMessage() openai.ChatCompletionMessageParamUnion {
+// ToOpenAIMessage converts the message for the chat completions API. With
+// vision, loaded images are sent as image_url parts. Other parts are
+// described by placeholders in the text.
+func (sm StoredMessage) ToOpenAIMessage(vision bool) openai.ChatCompletionMessageParamUnion {
 	switch sm.Role {
 	case "user":
-		return openai.UserMessage(sm.JSONify())
+		var images []openai.ChatCompletionContentPartUnionParam
+		var described []ContentPart
+		for _, part := range sm.Parts {
+			if vision && part.Type == "image" && len(part.Data) > 0 {
+				images = append(images, openai.ImagePart(part.dataURL()))
+			} else {
+				described = append(described, part)
+			}
+		}
+		sm.Parts = described
+		text := sm.withPlaceholders().JSONify()
+		if len(images) == 0 {
+			return openai.UserMessage(text)
+		}
+		return openai.UserMessageParts(append([]openai.ChatCompletionContentPartUnionParam{openai.TextPart(text)}, images...)...)
 	case "assistant":
 		return openai.AssistantMessage(sm.Content)
 	case "system":
@@ -27,6 +81,23 @@ func (sm StoredMessage) ToOpenAIMessage() openai.ChatCompletionMessageParamUnion
 	}
 }
 
+// withPlaceholders returns the message with "[image: filename]" placeholders
+// appended to its content for every part.
+func (sm StoredMessage) withPlaceholders() StoredMessage {
+	if len(sm.Parts) == 0 {
+		return sm
+	}
+	placeholders := make([]string, 0, len(sm.Parts)+1)
+	if sm.Content != "" {
+		placeholders = append(placeholders, sm.Content)
+	}
+	for _, part := range sm.Parts {
+		placeholders = append(placeholders, part.placeholder())
+	}
+	sm.Content = strings.Join(placeholders, " ")
+	return sm
+}
+
 func (sm StoredMessage) JSONify() string {
 	return fmt.Sprintf(`{"username": "%s", "source": "%s", "role": "%s", "content": "%s", "timestamp": "%s"}`,
 		sm.Username, sm.Source, sm.Role, sm.Content, sm.Timestamp.Format(time.RFC1123))
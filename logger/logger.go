@@ -2,19 +2,22 @@ package logger
 
 import "go.uber.org/zap"
 
-var Sugar *zap.SugaredLogger
-
-func InitLogger() error {
+// New creates the production logger used by NeighBot.
+func New() (*zap.SugaredLogger, error) {
 	logger, err := zap.NewProduction()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	Sugar = logger.Sugar()
-	return nil
+	return logger.Sugar(), nil
+}
+
+// Nop returns a logger discarding everything, for embedding and tests.
+func Nop() *zap.SugaredLogger {
+	return zap.NewNop().Sugar()
 }
 
-func SyncLogger() {
-	if Sugar != nil {
-		_ = Sugar.Sync() // flush any buffered logs
+func Sync(log *zap.SugaredLogger) {
+	if log != nil {
+		_ = log.Sync() // flush any buffered logs
 	}
 }
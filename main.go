@@ -1,26 +1,25 @@
 package main
 
 import (
-	"NeighBot/config"
-	"NeighBot/filters"
-	"NeighBot/llm"
+	"NeighBot/app"
 	"NeighBot/logger"
 	"flag"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 )
 
 func main() {
 	// Initialize logger
-	if err := logger.InitLogger(); err != nil {
+	log, err := logger.New()
+	if err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
-	defer logger.SyncLogger()
+	defer logger.Sync(log)
 
 	// Define flags
 	configDirFlag := flag.String("config-dir", "", "Path to configuration directory")
+	exportFeedbackFlag := flag.String("export-feedback", "", "Write rated responses as JSON lines to this file (- for stdout) and exit")
 	flag.Parse()
 
 	// Determine config directory by priority: ENV > Flag > Default
@@ -33,64 +32,27 @@ func main() {
 		panic("No config directory specified")
 	}
 
-	logger.Sugar.Infow("Starting NeighBot",
+	log.Infow("Starting NeighBot",
 		"config_dir", configDir,
 	)
-	// Ensure the directories exist
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		logger.Sugar.Fatalw("Failed to create config directory",
-			"config_dir", configDir,
-			"error", err,
-		)
-	}
-
-	mainConfigFile := filepath.Join(configDir, "main.json")
-
-	var mainConfig config.MainConfig
-	if _, err := os.Stat(mainConfigFile); os.IsNotExist(err) {
-		if err = mainConfig.CreateDefault(mainConfigFile); err != nil {
-			logger.Sugar.Fatalw("Failed to create default config", "error", err)
-		}
-		logger.Sugar.Infow("Created default config", "file", mainConfigFile)
-	} else {
-		if err = mainConfig.Load(mainConfigFile); err != nil {
-			logger.Sugar.Fatalw("Failed to load config", "error", err)
-		}
-	}
 
-	// Ensure the data directory exists
-	dataDir := filepath.Join(configDir, "data")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		logger.Sugar.Fatalw("Failed to create data directory", "data_dir", dataDir, "error", err)
-	}
-
-	// Initialize the memory store
-	memoryStore := llm.NewMemoryStore(dataDir)
-	if err := memoryStore.LoadAllContexts(); err != nil {
-		logger.Sugar.Fatalw("Failed to load contexts", "error", err)
+	neighBot, err := app.New(
+		app.WithConfigDir(configDir),
+		app.WithLogger(log),
+	)
+	if err != nil {
+		log.Fatalw("Failed to initialize NeighBot", "error", err)
 	}
 
-	if err := memoryStore.PopulateEmptyFolders(); err != nil {
-		logger.Sugar.Fatalw("Failed to populate empty folders", "error", err)
+	if *exportFeedbackFlag != "" {
+		if err = exportFeedback(neighBot, *exportFeedbackFlag); err != nil {
+			log.Fatalw("Failed to export feedback", "error", err)
+		}
+		return
 	}
 
-	// Initialize the LLM client
-	llmClient := llm.NewOpenAIClient(
-		mainConfig.LLM.APIKey,
-		mainConfig.LLM.Endpoint,
-		mainConfig.LLM.Model,
-	)
-	logger.Sugar.Infow("LLM client initialized",
-		"endpoint", mainConfig.LLM.Endpoint,
-		"model", mainConfig.LLM.Model,
-	)
-
-	// Initialize filters
-	filters.InitializeFilters()
-
-	// Initialize adapters
-	if err := HandleAdapters(&mainConfig, memoryStore, llmClient); err != nil {
-		logger.Sugar.Fatalw("Failed to initialize adapters", "error", err)
+	if err = neighBot.Start(); err != nil {
+		log.Fatalw("Failed to start NeighBot", "error", err)
 	}
 
 	// Create signal
@@ -100,22 +62,25 @@ func main() {
 	// Wait for a signal to quit
 	<-sc
 
-	// Stopping //
-	logger.Sugar.Info("Stopping NeighBot")
-
-	// Stop adapters
-	StopAdapters()
-
-	// Saving
-	logger.Sugar.Info("Saving contexts")
-	if err := memoryStore.SaveAllContexts(); err != nil {
-		logger.Sugar.Errorw("Failed to save contexts", "error", err)
+	if err = neighBot.Stop(); err != nil {
+		log.Errorw("Failed to stop NeighBot cleanly", "error", err)
+		return
 	}
+	log.Info("NeighBot stopped gracefully")
+}
 
-	logger.Sugar.Info("Saving config")
-	if err := mainConfig.Save(mainConfigFile); err != nil {
-		logger.Sugar.Errorw("Failed to save config", "error", err)
+func exportFeedback(neighBot *app.App, path string) error {
+	if path == "-" {
+		return neighBot.ExportFeedback(os.Stdout)
 	}
 
-	logger.Sugar.Info("NeighBot stopped gracefully")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = neighBot.ExportFeedback(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
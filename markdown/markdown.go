@@ -0,0 +1,219 @@
+// Package markdown tokenizes the Discord flavour of markdown the model writes
+// and renders it for other platforms.
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Kind int
+
+const (
+	Text Kind = iota
+	CodeBlock
+	InlineCode
+	Italic
+	Bold
+	Underline
+	Strike
+	Spoiler
+	Link
+)
+
+// Token is a piece of parsed markdown. Raw always holds the exact source,
+// so unchanged tokens render back to the input byte for byte.
+type Token struct {
+	Kind     Kind
+	Raw      string
+	Text     string  // Content of text and code tokens, text of links
+	Marker   string  // Delimiter of styled tokens, like "*" or "**"
+	Lang     string  // Language of code blocks
+	URL      string  // Target of links
+	Children []Token // Content of styled tokens
+}
+
+var (
+	fenceRegex = regexp.MustCompile("(?m)^[ \t]*```([^`\n]*)\n(?s:(.*?))\n?[ \t]*```[ \t]*$")
+	linkRegex  = regexp.MustCompile(`^\[([^\]\n]+)\]\((https?://[^\s)]+)\)`)
+)
+
+// Parse tokenizes the input. Fenced code blocks and inline code are kept
+// as-is, their content is never interpreted.
+func Parse(input string) []Token {
+	var tokens []Token
+	last := 0
+	for _, m := range fenceRegex.FindAllStringSubmatchIndex(input, -1) {
+		tokens = append(tokens, parseInline(input[last:m[0]])...)
+		tokens = append(tokens, Token{
+			Kind: CodeBlock,
+			Raw:  input[m[0]:m[1]],
+			Lang: strings.TrimSpace(input[m[2]:m[3]]),
+			Text: input[m[4]:m[5]],
+		})
+		last = m[1]
+	}
+	return append(tokens, parseInline(input[last:])...)
+}
+
+// delimiters in order of precedence, longer ones first.
+var delimiters = []struct {
+	marker string
+	kind   Kind
+}{
+	{"**", Bold},
+	{"__", Underline},
+	{"~~", Strike},
+	{"||", Spoiler},
+	{"*", Italic},
+	{"_", Italic},
+}
+
+func parseInline(input string) []Token {
+	var tokens []Token
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, Token{Kind: Text, Raw: text.String(), Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(input); {
+		c := input[i]
+
+		// Escaped characters are plain text
+		if c == '\\' && i+1 < len(input) && strings.IndexByte("\\*_~|`[]()<>#", input[i+1]) >= 0 {
+			text.WriteString(input[i : i+2])
+			i += 2
+			continue
+		}
+
+		if c == '`' {
+			if end := closingBackticks(input, i); end > 0 {
+				flush()
+				run := backtickRun(input, i)
+				tokens = append(tokens, Token{
+					Kind:   InlineCode,
+					Raw:    input[i:end],
+					Marker: input[i : i+run],
+					Text:   input[i+run : end-run],
+				})
+				i = end
+				continue
+			}
+		}
+
+		if c == '[' {
+			if m := linkRegex.FindStringSubmatch(input[i:]); m != nil {
+				flush()
+				tokens = append(tokens, Token{Kind: Link, Raw: m[0], Text: m[1], URL: m[2]})
+				i += len(m[0])
+				continue
+			}
+		}
+
+		if token, end, ok := parseDelimited(input, i); ok {
+			flush()
+			tokens = append(tokens, token)
+			i = end
+			continue
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return tokens
+}
+
+// parseDelimited parses a styled span opening at i. Openers must be followed
+// by a non-space and closers preceded by one. Single * and _ are not matched
+// inside words, so "2*3*4" and snake_case stay text. Single-character spans
+// do not cross lines.
+func parseDelimited(input string, i int) (Token, int, bool) {
+	for _, d := range delimiters {
+		marker := d.marker
+		if !strings.HasPrefix(input[i:], marker) {
+			continue
+		}
+		single := len(marker) == 1
+		if single && i+1 < len(input) && input[i+1] == marker[0] {
+			continue
+		}
+
+		start := i + len(marker)
+		next, _ := utf8.DecodeRuneInString(input[start:])
+		if start >= len(input) || unicode.IsSpace(next) {
+			continue
+		}
+		prev, _ := utf8.DecodeLastRuneInString(input[:i])
+		if single && i > 0 && isWordRune(prev) {
+			continue
+		}
+
+		for j := start + 1; j+len(marker) <= len(input); j++ {
+			if single && input[j] == '\n' {
+				break
+			}
+			if !strings.HasPrefix(input[j:], marker) {
+				continue
+			}
+			before, _ := utf8.DecodeLastRuneInString(input[:j])
+			if unicode.IsSpace(before) {
+				continue
+			}
+			end := j + len(marker)
+			if single {
+				if end < len(input) && input[end] == marker[0] {
+					j++
+					continue
+				}
+				after, _ := utf8.DecodeRuneInString(input[end:])
+				if end < len(input) && isWordRune(after) {
+					continue
+				}
+			}
+
+			inner := input[start:j]
+			return Token{
+				Kind:     d.kind,
+				Raw:      input[i:end],
+				Marker:   marker,
+				Children: parseInline(inner),
+			}, end, true
+		}
+	}
+	return Token{}, 0, false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func backtickRun(input string, i int) int {
+	run := 0
+	for i+run < len(input) && input[i+run] == '`' {
+		run++
+	}
+	return run
+}
+
+// closingBackticks returns the end of the inline code span opening at i, or 0.
+func closingBackticks(input string, i int) int {
+	run := backtickRun(input, i)
+	for j := i + run; j < len(input); {
+		if input[j] != '`' {
+			j++
+			continue
+		}
+		closing := backtickRun(input, j)
+		if closing == run {
+			return j + closing
+		}
+		j += closing
+	}
+	return 0
+}
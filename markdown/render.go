@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Dialect is a target formatting the markdown can be rendered to.
+type Dialect string
+
+const (
+	Discord  Dialect = "discord"
+	IRC      Dialect = "irc"
+	Telegram Dialect = "telegram_html"
+	Plain    Dialect = "plain"
+)
+
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(name); d {
+	case Discord, IRC, Telegram, Plain:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown dialect %q, expected %s, %s, %s or %s", name, Discord, IRC, Telegram, Plain)
+	}
+}
+
+// IRC control codes.
+const (
+	ircBold      = "\x02"
+	ircItalic    = "\x1D"
+	ircUnderline = "\x1F"
+	ircStrike    = "\x1E"
+	ircMonospace = "\x11"
+)
+
+var unescapeRegex = regexp.MustCompile(`\\([\\*_~|` + "`" + `\[\]()<>#])`)
+
+// Render renders the tokens in the dialect. Discord renders the source as-is.
+func Render(tokens []Token, dialect Dialect) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		renderToken(&b, token, dialect)
+	}
+	return b.String()
+}
+
+func renderToken(b *strings.Builder, token Token, dialect Dialect) {
+	if dialect == Discord {
+		b.WriteString(token.Raw)
+		return
+	}
+
+	switch token.Kind {
+	case Text:
+		text := unescapeRegex.ReplaceAllString(token.Text, "$1")
+		if dialect == Telegram {
+			text = html.EscapeString(text)
+		}
+		b.WriteString(text)
+
+	case CodeBlock:
+		switch dialect {
+		case Telegram:
+			if token.Lang != "" {
+				fmt.Fprintf(b, `<pre><code class="language-%s">%s</code></pre>`, html.EscapeString(token.Lang), html.EscapeString(token.Text))
+			} else {
+				fmt.Fprintf(b, "<pre>%s</pre>", html.EscapeString(token.Text))
+			}
+		default:
+			// No code block formatting, keep the block readable as-is
+			b.WriteString(token.Raw)
+		}
+
+	case InlineCode:
+		switch dialect {
+		case Telegram:
+			fmt.Fprintf(b, "<code>%s</code>", html.EscapeString(token.Text))
+		case IRC:
+			b.WriteString(ircMonospace + token.Text + ircMonospace)
+		default:
+			b.WriteString(token.Text)
+		}
+
+	case Link:
+		switch dialect {
+		case Telegram:
+			fmt.Fprintf(b, `<a href="%s">%s</a>`, html.EscapeString(token.URL), html.EscapeString(token.Text))
+		default:
+			fmt.Fprintf(b, "%s (%s)", token.Text, token.URL)
+		}
+
+	default:
+		open, closing := styleTags(token.Kind, dialect)
+		b.WriteString(open)
+		for _, child := range token.Children {
+			renderToken(b, child, dialect)
+		}
+		b.WriteString(closing)
+	}
+}
+
+func styleTags(kind Kind, dialect Dialect) (string, string) {
+	switch dialect {
+	case IRC:
+		code := map[Kind]string{Bold: ircBold, Italic: ircItalic, Underline: ircUnderline, Strike: ircStrike}[kind]
+		return code, code
+	case Telegram:
+		tag := map[Kind]string{Bold: "b", Italic: "i", Underline: "u", Strike: "s", Spoiler: "tg-spoiler"}[kind]
+		if tag == "" {
+			return "", ""
+		}
+		return "<" + tag + ">", "</" + tag + ">"
+	default:
+		return "", ""
+	}
+}
+
+// RemoveActions removes roleplay actions, spans in single asterisks like
+// *waves*, leaving all other formatting untouched. The space left behind by
+// an action is collapsed.
+func RemoveActions(tokens []Token) []Token {
+	kept := make([]Token, 0, len(tokens))
+	removed := false
+	for _, token := range tokens {
+		if token.Kind == Italic && token.Marker == "*" {
+			removed = true
+			continue
+		}
+		if len(token.Children) > 0 {
+			children := RemoveActions(token.Children)
+			if len(children) != len(token.Children) {
+				token.Children = children
+				token.Raw = token.Marker + Render(children, Discord) + token.Marker
+			}
+		}
+		if removed && token.Kind == Text && strings.HasPrefix(token.Text, " ") && endsWithSpace(kept) {
+			token.Text = token.Text[1:]
+			token.Raw = token.Raw[1:]
+		}
+		removed = false
+		kept = append(kept, token)
+	}
+
+	if removed && len(kept) > 0 && kept[len(kept)-1].Kind == Text {
+		last := &kept[len(kept)-1]
+		last.Text = strings.TrimRight(last.Text, " ")
+		last.Raw = strings.TrimRight(last.Raw, " ")
+	}
+	return kept
+}
+
+// endsWithSpace reports whether the tokens are empty or end with a space.
+func endsWithSpace(tokens []Token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.Kind == Text && strings.HasSuffix(last.Text, " ")
+}
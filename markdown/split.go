@@ -0,0 +1,158 @@
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// fenceClose closes a code block cut at the end of a chunk.
+const fenceClose = "\n```"
+
+// Split splits the input into chunks of at most limit runes, for platforms
+// limiting message length. Chunks end at paragraph breaks if possible, then
+// at line breaks, sentence ends and spaces, and only cut words that do not
+// fit at all. Code blocks cut between chunks are closed and re-opened with
+// the same language. A limit below 1 leaves the input in one chunk.
+func Split(input string, limit int) []string {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil
+	}
+	if limit <= 0 {
+		return []string{input}
+	}
+
+	var chunks []string
+	opener := "" // Fence re-opened at the start of the next chunk
+	for input != "" {
+		if opener != "" && (input == "```" || strings.HasPrefix(input, "```\n")) {
+			// The code block ends where the last chunk closed it
+			input = strings.TrimLeftFunc(strings.TrimPrefix(input, "```"), isSpace)
+			opener = ""
+			continue
+		}
+
+		prefix := ""
+		if opener != "" {
+			prefix = opener + "\n"
+		}
+		if utf8.RuneCountInString(prefix)+utf8.RuneCountInString(input) <= limit {
+			chunks = append(chunks, prefix+input)
+			break
+		}
+
+		budget := limit - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(fenceClose)
+		if budget < limit/2 {
+			// Fences take up too much of the chunk, continue without them
+			prefix, opener = "", ""
+			budget = limit
+		}
+
+		cut := cutPoint(input, budget)
+		if line := trailingOpener(prefix, input[:cut]); line > 0 {
+			// Leave the code block to the next chunk rather than end with its opener
+			cut = line
+		} else if line == 0 {
+			// The chunk starts with the opener, include some of the code
+			body := strings.IndexByte(input, '\n') + 1
+			if rest := budget - utf8.RuneCountInString(input[:body]); body > 0 && rest > 0 {
+				cut = body + cutPoint(input[body:], rest)
+			}
+		}
+		chunk := prefix + input[:cut]
+		input = input[cut:]
+
+		if open, lang := fenceState(chunk); open && budget < limit {
+			chunk = strings.TrimRight(chunk, "\n") + fenceClose
+			opener = "```" + lang
+			input = strings.TrimPrefix(input, "\n")
+		} else {
+			chunk = strings.TrimRightFunc(chunk, isSpace)
+			opener = ""
+			input = strings.TrimLeftFunc(input, isSpace)
+		}
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// cutPoint returns the byte offset to end a chunk of at most budget runes
+// at, never inside a run of backticks.
+func cutPoint(input string, budget int) int {
+	cut := breakPoint(input, budget)
+	for cut > 0 && cut < len(input) && input[cut-1] == '`' && input[cut] == '`' {
+		cut--
+	}
+	if cut == 0 {
+		cut = backtickRun(input, 0)
+	}
+	return cut
+}
+
+// breakPoint returns the byte offset of the best break in the first budget runes.
+func breakPoint(input string, budget int) int {
+	window := input
+	if n := runeOffset(input, budget); n < len(input) {
+		window = input[:n]
+	}
+	minimum := len(window) / 3
+
+	for _, sep := range []string{"\n\n", "\n", ". ", "! ", "? ", "; ", ", "} {
+		if i := strings.LastIndex(window, sep); i > 0 && i >= minimum {
+			return i + len(sep)
+		}
+	}
+	if i := strings.LastIndexAny(window, " \t"); i > 0 {
+		return i + 1
+	}
+	return len(window)
+}
+
+// runeOffset returns the byte offset of the n-th rune, or len(s).
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// trailingOpener returns the byte offset in text of the line opening a code
+// block with nothing after it, or -1 if text does not end with one.
+func trailingOpener(prefix, text string) int {
+	trimmed := strings.TrimRightFunc(text, isSpace)
+	start := strings.LastIndexByte(trimmed, '\n') + 1
+	if !strings.HasPrefix(strings.TrimSpace(trimmed[start:]), "```") {
+		return -1
+	}
+	if open, _ := fenceState(prefix + trimmed); !open {
+		return -1
+	}
+	return start
+}
+
+// fenceState reports whether the text ends inside a fenced code block, and
+// the language of that block.
+func fenceState(text string) (bool, string) {
+	open, lang := false, ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "```") {
+			continue
+		}
+		if open {
+			open, lang = false, ""
+		} else if !strings.Contains(line[3:], "```") {
+			open, lang = true, strings.TrimSpace(line[3:])
+		}
+	}
+	return open, lang
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,128 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestSplitCodeBlockOpener(t *testing.T) {
+	chunks := Split("```go\naaaa aaaa aaaa aaaa\n\naaaa aaaa aaaa\n```", 20)
+	for _, chunk := range chunks {
+		if open, _ := fenceState(chunk); open {
+			t.Errorf("chunk %q leaves a code block open", chunk)
+		}
+		if strings.TrimSpace(content(chunk)) == "" {
+			t.Errorf("chunk %q has no content", chunk)
+		}
+	}
+	if !strings.HasPrefix(chunks[0], "```go\naaaa") {
+		t.Errorf("first chunk = %q, want code", chunks[0])
+	}
+}
+
+func TestSplitLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"zero", 0, []string{"hay there neigh"}},
+		{"negative", -5, []string{"hay there neigh"}},
+		{"one", 1, []string{"h", "a", "y", "t", "h", "e", "r", "e", "n", "e", "i", "g", "h"}},
+		{"word", 4, []string{"hay", "ther", "e", "neig", "h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(" hay there neigh ", tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Split(limit %d) = %q, want %q", tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzSplit(f *testing.F) {
+	f.Add("Hello there. This is a sentence, and another one!\n\nNew paragraph.", 32)
+	f.Add("```go\naaaa aaaa aaaa aaaa\n\naaaa aaaa aaaa\n```", 20)
+	f.Add("text before\n```py\nprint('hay')\nprint('neigh')\n```\ntext after", 32)
+	f.Add("ünïcödé 🐴🐴🐴 wörds "+strings.Repeat("ö", 50), 24)
+	f.Add(strings.Repeat("word ", 100), 50)
+	f.Add("```go\naaaa\n```", 0)
+	f.Add("hay there", -1)
+	f.Add("```go\naaaa\n```", 3)
+
+	f.Fuzz(func(t *testing.T, input string, limit int) {
+		if !utf8.ValidString(input) || limit > 2000 {
+			t.Skip()
+		}
+		chunks := Split(input, limit)
+		if limit < 20 {
+			// Tiny limits only need to terminate, without the fences fitting
+			if limit <= 0 && len(chunks) > 1 {
+				t.Errorf("got %d chunks without a limit", len(chunks))
+			}
+			return
+		}
+
+		fences := simpleFences(input)
+		for _, chunk := range chunks {
+			if n := utf8.RuneCountInString(chunk); n > limit {
+				t.Errorf("chunk of %d runes over limit %d: %q", n, limit, chunk)
+			}
+			if !utf8.ValidString(chunk) {
+				t.Errorf("invalid UTF-8 in chunk %q", chunk)
+			}
+			if open, _ := fenceState(chunk); open && fences && limit >= 32 {
+				t.Errorf("unbalanced fences in chunk %q", chunk)
+			}
+		}
+
+		// Nothing but whitespace and fences is added or lost
+		if !fences {
+			return
+		}
+		var joined strings.Builder
+		for _, chunk := range chunks {
+			joined.WriteString(content(chunk))
+		}
+		if got, want := joined.String(), content(input); got != want {
+			t.Errorf("content changed:\n got %q\nwant %q\nchunks %q", got, want, chunks)
+		}
+	})
+}
+
+// simpleFences reports whether the code blocks of the input are balanced,
+// their fences short and backticks used nowhere else, so that chunks can be
+// compared to it.
+func simpleFences(input string) bool {
+	fenceLines := 0
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") {
+			if len(line) > 10 || strings.ContainsAny(line, " \t\r") || strings.Count(line, "`") != 3 {
+				return false
+			}
+			fenceLines++
+		}
+	}
+	return fenceLines%2 == 0 && strings.Count(input, "`") == 3*fenceLines
+}
+
+// content returns the text of the lines that are not fences, without
+// whitespace.
+func content(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		for _, r := range line {
+			if !unicode.IsSpace(r) {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
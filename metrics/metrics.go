@@ -0,0 +1,52 @@
+// Package metrics counts events of a running NeighBot, like response outcomes.
+package metrics
+
+import (
+	"maps"
+	"sync"
+)
+
+// Metrics is a set of named counters. A nil *Metrics discards everything,
+// so components can be used without one.
+type Metrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+func New() *Metrics {
+	return &Metrics{counters: make(map[string]int64)}
+}
+
+// Inc increments the counter by one.
+func (m *Metrics) Inc(name string) {
+	m.Add(name, 1)
+}
+
+func (m *Metrics) Add(name string, delta int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.counters[name] += delta
+	m.mu.Unlock()
+}
+
+// Get returns the current value of the counter.
+func (m *Metrics) Get(name string) int64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// Snapshot returns a copy of all counters.
+func (m *Metrics) Snapshot() map[string]int64 {
+	if m == nil {
+		return map[string]int64{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.counters)
+}
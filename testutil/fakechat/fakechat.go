@@ -0,0 +1,224 @@
+// Package fakechat provides a chat adapter driven from code, capturing the
+// messages NeighBot sends, for end-to-end scenarios without a chat platform.
+package fakechat
+
+import (
+	"NeighBot/adapters"
+	"NeighBot/llm"
+	"NeighBot/triggers"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type Config struct {
+	adapters.ChatAdapterConfig
+	BotName string `json:"bot_name"` // Name users mention to get a response, defaults to NeighBot
+}
+
+// Outbound is a message sent by the adapter, or a reaction to the
+// triggering message.
+type Outbound struct {
+	ChatID    string
+	MessageID string // Assigned by the fake chat, for rating the message
+	Content   string
+	Reaction  string // Emoji the bot reacted with instead of replying
+}
+
+type Adapter struct {
+	config   Config
+	pipeline *adapters.Pipeline
+
+	mu            sync.Mutex
+	running       bool
+	sent          []Outbound
+	failReactions bool
+	failed        chan error
+}
+
+func (a *Adapter) SetConfig(cfg interface{}) error {
+	c, ok := cfg.(*Config)
+	if !ok {
+		return errors.New("invalid config type for fake chat adapter")
+	}
+	a.config = *c
+	if a.config.BotName == "" {
+		a.config.BotName = "NeighBot"
+	}
+	return nil
+}
+
+func (a *Adapter) Initialize() error {
+	a.pipeline = adapters.NewPipeline(&a.config.ChatAdapterConfig)
+	return nil
+}
+
+func (a *Adapter) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running = true
+	a.failed = make(chan error, 1)
+	return nil
+}
+
+func (a *Adapter) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running = false
+	return nil
+}
+
+func (a *Adapter) Identity() adapters.AdapterIdentity {
+	return a.config.Identity()
+}
+
+func (a *Adapter) Failed() <-chan error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.failed
+}
+
+// Disconnect makes the running adapter fail, as if it lost its connection.
+func (a *Adapter) Disconnect(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running {
+		return
+	}
+	a.running = false
+	select {
+	case a.failed <- err:
+	default:
+	}
+}
+
+// Message is a message written by a user in the fake chat.
+type Message struct {
+	ChatID     string
+	MessageID  string
+	UserID     string
+	Username   string
+	Content    string
+	Images     []adapters.Attachment
+	ReplyToBot bool // Replies to one of the bot's messages
+	Direct     bool // Sent in a direct message, routed to a per-user context
+}
+
+// Deliver handles a message as if a user wrote it in the chat, see DeliverMessage.
+func (a *Adapter) Deliver(chatID, username, content string) (string, error) {
+	return a.DeliverMessage(Message{ChatID: chatID, Username: username, Content: content})
+}
+
+// DeliverMessage handles a message as if a user wrote it in the chat.
+// Messages mentioning "@<bot name>" or matching another trigger rule of the
+// context get a response, which is returned and captured. Reactions are
+// captured with an empty response. Messages to chats without a context are
+// ignored.
+func (a *Adapter) DeliverMessage(msg Message) (string, error) {
+	a.mu.Lock()
+	running := a.running
+	a.mu.Unlock()
+	if !running {
+		return "", errors.New("fake chat adapter not running")
+	}
+
+	var ctx *llm.StoredContext
+	if msg.Direct {
+		ctx = a.pipeline.RouteDirect(msg.ChatID, msg.UserID, msg.Username)
+	} else {
+		ctx = a.pipeline.Route(msg.ChatID)
+	}
+	if ctx == nil {
+		return "", nil
+	}
+
+	source := fmt.Sprintf("%s:%s", a.Identity().Name, msg.ChatID)
+	if err := a.pipeline.Record(ctx, adapters.InboundMessage{
+		ChatID:    msg.ChatID,
+		MessageID: msg.MessageID,
+		Source:    source,
+		UserID:    msg.UserID,
+		Username:  msg.Username,
+		Content:   msg.Content,
+		Images:    msg.Images,
+	}); err != nil {
+		if errors.Is(err, adapters.ErrDropped) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if !a.pipeline.ShouldRespond(ctx, triggers.Message{
+		Content:    msg.Content,
+		Mentioned:  strings.Contains(msg.Content, "@"+a.config.BotName),
+		ReplyToBot: msg.ReplyToBot,
+		Direct:     msg.Direct,
+	}) {
+		return "", nil
+	}
+
+	response, reaction, err := a.pipeline.Respond(context.Background(), ctx, source, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if reaction != "" {
+		a.mu.Lock()
+		failed := a.failReactions
+		if !failed {
+			a.sent = append(a.sent, Outbound{ChatID: msg.ChatID, Reaction: reaction})
+		}
+		a.mu.Unlock()
+		if !failed {
+			return "", nil
+		}
+		// Reply instead, like adapters do when reacting fails
+		if response, err = a.pipeline.ReactionFailed(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	a.mu.Lock()
+	messageID := fmt.Sprintf("sent-%d", len(a.sent)+1)
+	a.sent = append(a.sent, Outbound{ChatID: msg.ChatID, MessageID: messageID, Content: response})
+	a.mu.Unlock()
+	return response, a.pipeline.Sent(ctx, messageID)
+}
+
+// FailReactions makes reactions fail, as if the platform refused them.
+func (a *Adapter) FailReactions(fail bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failReactions = fail
+}
+
+// Rate rates a message sent by the adapter as a user, 1 for good and -1 for
+// bad, as if they reacted to it. A rating of 0 removes the user's rating.
+func (a *Adapter) Rate(chatID, messageID, userID string, rating int) error {
+	ctx := a.pipeline.Route(chatID)
+	if ctx == nil {
+		return nil
+	}
+	if rating == 0 {
+		message, ok := ctx.Message(messageID)
+		if !ok {
+			return nil
+		}
+		for _, feedback := range message.Feedback {
+			if feedback.UserID == userID {
+				return a.pipeline.RemoveFeedback(ctx, messageID, userID, feedback.Rating)
+			}
+		}
+		return nil
+	}
+	return a.pipeline.AddFeedback(ctx, messageID, userID, rating)
+}
+
+// Sent returns the messages sent by the adapter so far.
+func (a *Adapter) Sent() []Outbound {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Outbound(nil), a.sent...)
+}
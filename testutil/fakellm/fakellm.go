@@ -0,0 +1,290 @@
+// Package fakellm provides an in-process OpenAI-compatible server for
+// exercising NeighBot without a real model.
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reply scripts the server's answer to one chat completion request.
+type Reply struct {
+	Content string        // Assistant message content
+	Delay   time.Duration // Latency added before answering
+	Status  int           // Non-zero to answer with an HTTP error instead
+	Error   string        // Error message sent along with Status
+}
+
+// Message is a chat message received by the server.
+type Message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Text returns the message content, joining the text parts of multi-part content.
+func (m Message) Text() string {
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return string(m.Content)
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		if part.Type == "text" {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// Request is a chat completion request received by the server.
+type Request struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+}
+
+// LastMessage returns the last message of the request, or an empty one.
+func (r Request) LastMessage() Message {
+	if len(r.Messages) == 0 {
+		return Message{}
+	}
+	return r.Messages[len(r.Messages)-1]
+}
+
+// Server is a fake OpenAI-compatible endpoint supporting chat completions,
+// streaming and the /tokenize endpoint. Replies are taken from a script,
+// falling back to a responder function and then to a default reply.
+type Server struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	script    []Reply
+	responder func(Request) Reply
+	fallback  Reply
+	latency   time.Duration
+	requests  []Request
+}
+
+// New starts a server. Close it when done.
+func New() *Server {
+	s := &Server{
+		fallback: Reply{Content: "Neigh!"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("POST /tokenize", s.handleTokenize)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Endpoint returns the base URL to use as the LLM endpoint in NeighBot's config.
+func (s *Server) Endpoint() string {
+	return s.server.URL
+}
+
+// Enqueue appends replies to the script, they are used in order.
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, replies...)
+}
+
+// Respond sets a function answering requests once the script is exhausted.
+func (s *Server) Respond(responder func(Request) Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responder = responder
+}
+
+// SetDefault sets the reply used when neither the script nor a responder applies.
+func (s *Server) SetDefault(reply Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = reply
+}
+
+// SetLatency adds a delay to every request, on top of per-reply delays.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// FailNext makes the next request fail with an HTTP error.
+// Note that OpenAI clients retry 429 and 5xx errors by default.
+func (s *Server) FailNext(status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append([]Reply{{Status: status, Error: message}}, s.script...)
+}
+
+// Requests returns the chat completion requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) nextReply(req Request) (Reply, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	if len(s.script) > 0 {
+		reply := s.script[0]
+		s.script = s.script[1:]
+		return reply, s.latency
+	}
+	if s.responder != nil {
+		return s.responder(req), s.latency
+	}
+	return s.fallback, s.latency
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	reply, latency := s.nextReply(req)
+	if !sleep(r, latency+reply.Delay) {
+		return
+	}
+
+	if reply.Status != 0 {
+		writeError(w, reply.Status, reply.Error)
+		return
+	}
+
+	if req.Stream {
+		writeStream(w, req.Model, reply.Content)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-fake",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"finish_reason": "stop",
+			"message": map[string]interface{}{
+				"role":    "assistant",
+				"content": reply.Content,
+			},
+		}},
+		"usage": map[string]interface{}{
+			"prompt_tokens":     0,
+			"completion_tokens": len(strings.Fields(reply.Content)),
+			"total_tokens":      len(strings.Fields(reply.Content)),
+		},
+	})
+}
+
+// writeStream sends the content as server-sent events, one word per chunk.
+func writeStream(w http.ResponseWriter, model, content string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(delta map[string]interface{}, finishReason interface{}) {
+		chunk, _ := json.Marshal(map[string]interface{}{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			}},
+		})
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(map[string]interface{}{"role": "assistant", "content": ""}, nil)
+	for _, piece := range strings.SplitAfter(content, " ") {
+		if piece != "" {
+			send(map[string]interface{}{"content": piece}, nil)
+		}
+	}
+	send(map[string]interface{}{}, "stop")
+	_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// handleTokenize mimics the llama.cpp /tokenize endpoint, one token per word.
+func (s *Server) handleTokenize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	if !sleep(r, latency) {
+		return
+	}
+
+	tokens := make([]int, len(strings.Fields(req.Content)))
+	for i := range tokens {
+		tokens[i] = i
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"tokens": tokens})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "fake_error",
+		},
+	})
+}
+
+// sleep waits for the delay, returning false if the request was cancelled.
+func sleep(r *http.Request, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(delay):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
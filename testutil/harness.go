@@ -0,0 +1,118 @@
+// Package testutil wires NeighBot to a fake LLM server and a fake chat
+// adapter, so scenarios run entirely offline.
+package testutil
+
+import (
+	"NeighBot/adapters"
+	"NeighBot/app"
+	"NeighBot/config"
+	"NeighBot/llm"
+	"NeighBot/testutil/fakechat"
+	"NeighBot/testutil/fakellm"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ChatInstance is the adapter instance name of the harness' fake chat.
+const ChatInstance = "fake"
+
+type Harness struct {
+	Dir  string
+	LLM  *fakellm.Server
+	App  *app.App
+	Chat *fakechat.Adapter
+}
+
+// NewHarness starts an App in dir, which should be empty, talking to a fresh
+// fake LLM server through a single fake chat adapter instance.
+// Extra options are applied after the harness' own ones. Call Close when done.
+func NewHarness(dir string, opts ...app.Option) (*Harness, error) {
+	h := &Harness{
+		Dir: dir,
+		LLM: fakellm.New(),
+	}
+
+	mainConfig := config.MainConfig{
+		LLM: config.LLMConfig{
+			APIKey:   "-",
+			Endpoint: h.LLM.Endpoint(),
+			Model:    "fake-model",
+		},
+		Adapters: config.AdaptersConfig{
+			Configs: map[string]interface{}{
+				ChatInstance: map[string]interface{}{
+					"type":    "fakechat",
+					"enabled": true,
+				},
+			},
+		},
+	}
+	if err := mainConfig.Save(filepath.Join(dir, "main.json")); err != nil {
+		h.LLM.Close()
+		return nil, err
+	}
+
+	appOpts := append([]app.Option{
+		app.WithConfigDir(dir),
+		app.WithAdapterType("fakechat", &fakechat.Adapter{}, fakechat.Config{}),
+		app.WithoutConfigReload(),
+	}, opts...)
+
+	var err error
+	if h.App, err = app.New(appOpts...); err != nil {
+		h.LLM.Close()
+		return nil, err
+	}
+	if err = h.App.Start(); err != nil {
+		h.LLM.Close()
+		return nil, err
+	}
+
+	adapter, err := h.App.Adapter(ChatInstance)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	chat, ok := adapter.(*fakechat.Adapter)
+	if !ok {
+		h.Close()
+		return nil, fmt.Errorf("unexpected adapter type %T", adapter)
+	}
+	h.Chat = chat
+
+	// Adapters are started in the background
+	if err = h.waitRunning(5 * time.Second); err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *Harness) waitRunning(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, status := range h.App.AdapterStatuses() {
+			if status.Name == ChatInstance && status.State == adapters.StateRunning.String() {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("adapter %s did not start within %s", ChatInstance, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// AddContext creates a context associated with the given fake chats.
+func (h *Harness) AddContext(contextID string, chatIDs ...string) *llm.StoredContext {
+	ctx := h.App.MemoryStore().CreateContext(contextID)
+	ctx.AssociatedChats = append(ctx.AssociatedChats, chatIDs...)
+	return ctx
+}
+
+func (h *Harness) Close() error {
+	defer h.LLM.Close()
+	return h.App.Stop()
+}
@@ -0,0 +1,221 @@
+package testutil_test
+
+import (
+	"NeighBot/filters"
+	"NeighBot/llm"
+	"NeighBot/testutil"
+	"NeighBot/testutil/fakechat"
+	"NeighBot/testutil/fakellm"
+	"strings"
+	"testing"
+)
+
+func newHarness(t *testing.T, dir string) *testutil.Harness {
+	t.Helper()
+	h, err := testutil.NewHarness(dir)
+	if err != nil {
+		t.Fatalf("NewHarness: %v", err)
+	}
+	return h
+}
+
+func deliver(t *testing.T, h *testutil.Harness, chatID, content string) string {
+	t.Helper()
+	response, err := h.Chat.Deliver(chatID, "ann", content)
+	if err != nil {
+		t.Fatalf("Deliver(%q, %q): %v", chatID, content, err)
+	}
+	return response
+}
+
+func TestMemoryScenario(t *testing.T) {
+	dir := t.TempDir()
+	h := newHarness(t, dir)
+	h.AddContext("stable", "general")
+	h.LLM.Enqueue(fakellm.Reply{Content: "Hay there."}, fakellm.Reply{Content: "Still here."})
+
+	deliver(t, h, "general", "@NeighBot hello")
+	deliver(t, h, "general", "@NeighBot remember me?")
+
+	// The second request sees the first exchange
+	requests := h.LLM.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	var prompt []string
+	for _, message := range requests[1].Messages {
+		prompt = append(prompt, message.Text())
+	}
+	joined := strings.Join(prompt, "\n")
+	for _, want := range []string{"@NeighBot hello", "Hay there.", "@NeighBot remember me?"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("second prompt lacks %q:\n%s", want, joined)
+		}
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Memory survives a restart
+	h = newHarness(t, dir)
+	defer h.Close()
+	ctx := h.App.MemoryStore().GetContext("stable")
+	if ctx == nil {
+		t.Fatal("context not loaded after restart")
+	}
+	var roles []string
+	for _, message := range ctx.PromptMessages() {
+		if message.Role != "system" {
+			roles = append(roles, message.Role)
+		}
+	}
+	if got, want := strings.Join(roles, ","), "user,assistant,user,assistant"; got != want {
+		t.Errorf("stored roles = %s, want %s", got, want)
+	}
+}
+
+func TestFilterScenario(t *testing.T) {
+	h := newHarness(t, t.TempDir())
+	defer h.Close()
+
+	ctx := h.AddContext("stable", "general")
+	ctx.Filters = filters.Specs{
+		{Name: "remove_emojis"},
+		{Name: "blocklist", Params: filters.Params{"words": []string{"oats"}, "action": "reject"}},
+	}
+	ctx.Regeneration = llm.RegenerationConfig{MaxRetries: 1, Fallback: "No comment."}
+	if err := ctx.InitializeFilters(); err != nil {
+		t.Fatalf("InitializeFilters: %v", err)
+	}
+
+	h.LLM.Enqueue(fakellm.Reply{Content: "Nice 🐴 day"})
+	if got := deliver(t, h, "general", "@NeighBot hi"); got != "Nice day" {
+		t.Errorf("filtered response = %q, want %q", got, "Nice day")
+	}
+
+	// Rejected responses are regenerated with a nudge, then fall back
+	h.LLM.Enqueue(fakellm.Reply{Content: "I love oats"}, fakellm.Reply{Content: "Oats again"})
+	if got := deliver(t, h, "general", "@NeighBot snack?"); got != "No comment." {
+		t.Errorf("response = %q, want the fallback", got)
+	}
+	requests := h.LLM.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+	if last := requests[2].LastMessage(); last.Role != "system" {
+		t.Errorf("retry ends with a %s message, want the system nudge", last.Role)
+	}
+}
+
+func TestRoutingScenario(t *testing.T) {
+	h := newHarness(t, t.TempDir())
+	defer h.Close()
+
+	h.AddContext("barn", "barn-chat")
+	h.AddContext("field", "field-chat")
+	h.LLM.Respond(func(req fakellm.Request) fakellm.Reply {
+		return fakellm.Reply{Content: "re: " + req.LastMessage().Text()}
+	})
+
+	// Unknown chats are ignored without asking the model
+	if got := deliver(t, h, "elsewhere", "@NeighBot hi"); got != "" {
+		t.Errorf("unknown chat got response %q", got)
+	}
+	// Messages without a trigger are stored but not answered
+	if got := deliver(t, h, "barn-chat", "just chatting"); got != "" {
+		t.Errorf("untriggered message got response %q", got)
+	}
+	if len(h.LLM.Requests()) != 0 {
+		t.Fatalf("got %d requests, want none", len(h.LLM.Requests()))
+	}
+
+	deliver(t, h, "barn-chat", "@NeighBot in the barn")
+	deliver(t, h, "field-chat", "@NeighBot in the field")
+	store := h.App.MemoryStore()
+	for contextID, want := range map[string]string{"barn": "in the barn", "field": "in the field"} {
+		var contents []string
+		for _, message := range store.GetContext(contextID).PromptMessages() {
+			contents = append(contents, message.Content)
+		}
+		joined := strings.Join(contents, "\n")
+		if !strings.Contains(joined, want) {
+			t.Errorf("context %s lacks %q", contextID, want)
+		}
+		if contextID == "barn" && strings.Contains(joined, "in the field") {
+			t.Errorf("context barn got the field's message")
+		}
+	}
+
+	// Direct messages get a context of their own
+	response, err := h.Chat.DeliverMessage(fakechat.Message{ChatID: "dm-1", UserID: "u1", Username: "ann", Content: "hello", Direct: true})
+	if err != nil {
+		t.Fatalf("DeliverMessage: %v", err)
+	}
+	if response == "" {
+		t.Error("direct message got no response")
+	}
+	if store.GetContext("dm-"+testutil.ChatInstance+"-u1") == nil {
+		t.Error("no context created for the direct message")
+	}
+}
+
+func TestReactionScenario(t *testing.T) {
+	h := newHarness(t, t.TempDir())
+	defer h.Close()
+
+	ctx := h.AddContext("stable", "general")
+	ctx.Reactions = true
+	ctx.Regeneration = llm.RegenerationConfig{Fallback: "Noted."}
+
+	h.LLM.Enqueue(fakellm.Reply{Content: "[react:👍🏽]"})
+	deliver(t, h, "general", "@NeighBot thanks")
+	sent := h.Chat.Sent()
+	if len(sent) != 1 || sent[0].Reaction != "👍🏽" {
+		t.Fatalf("sent %+v, want a 👍🏽 reaction", sent)
+	}
+
+	// Reactions that are not an emoji are regenerated
+	h.LLM.Enqueue(fakellm.Reply{Content: "[react:thumbs]"}, fakellm.Reply{Content: "You're welcome"})
+	if got := deliver(t, h, "general", "@NeighBot thanks again"); got != "You're welcome" {
+		t.Errorf("response = %q, want the regenerated reply", got)
+	}
+
+	// Failed reactions are replaced with the fallback, in memory as well
+	h.Chat.FailReactions(true)
+	h.LLM.Enqueue(fakellm.Reply{Content: "[react:🐴]"})
+	if got := deliver(t, h, "general", "@NeighBot neigh"); got != "Noted." {
+		t.Errorf("response = %q, want the fallback", got)
+	}
+	messages := ctx.PromptMessages()
+	if last := messages[len(messages)-1]; last.Role != "assistant" || last.Content != "Noted." || last.MessageID == "" {
+		t.Errorf("last stored message = %+v, want the sent fallback", last)
+	}
+}
+
+func TestReactionReloadScenario(t *testing.T) {
+	h := newHarness(t, t.TempDir())
+	defer h.Close()
+
+	ctx := h.AddContext("stable", "general")
+	ctx.Reactions = true
+	reload := &llm.StoredContext{
+		ID:              ctx.ID,
+		Name:            ctx.Name,
+		AssociatedChats: ctx.AssociatedChats,
+		Filters:         ctx.Filters,
+		FilterManager:   ctx.FilterManager,
+		InputFilters:    ctx.InputFilters,
+		InputManager:    ctx.InputManager,
+	}
+
+	// Reactions turned off while generating still react, never post the sentinel
+	h.LLM.Respond(func(fakellm.Request) fakellm.Reply {
+		ctx.ApplyConfig(reload)
+		return fakellm.Reply{Content: "[react:👍]"}
+	})
+	deliver(t, h, "general", "@NeighBot thanks")
+	sent := h.Chat.Sent()
+	if len(sent) != 1 || sent[0].Reaction != "👍" || sent[0].Content != "" {
+		t.Fatalf("sent %+v, want a 👍 reaction", sent)
+	}
+}
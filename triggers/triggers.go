@@ -0,0 +1,143 @@
+// Package triggers decides whether the bot responds to an incoming message.
+package triggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules configure when the bot responds in a context. Any matching rule
+// triggers a response.
+type Rules struct {
+	Mentions bool     `json:"mentions"`        // Messages @-mentioning the bot
+	Replies  bool     `json:"replies"`         // Replies to the bot's messages
+	Direct   bool     `json:"direct_messages"` // Every message in direct messages
+	Names    []string `json:"names"`           // Names the bot answers to as plain words, case-insensitive
+	Keywords []string `json:"keywords"`        // Regular expressions
+	ChimeIn  float64  `json:"chime_in"`        // Probability of responding to any other message, 0 to 1
+}
+
+// DefaultRules respond to mentions, replies and direct messages.
+func DefaultRules() Rules {
+	return Rules{Mentions: true, Replies: true, Direct: true}
+}
+
+// UnmarshalJSON fills in the fields missing from the data with the defaults,
+// so e.g. adding keywords keeps responding to mentions.
+func (r *Rules) UnmarshalJSON(data []byte) error {
+	type plain Rules
+	rules := plain(DefaultRules())
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	*r = Rules(rules)
+	return nil
+}
+
+// Message is what the rules see of an incoming message.
+type Message struct {
+	Content    string
+	Mentioned  bool // The bot was @-mentioned
+	ReplyToBot bool // The message replies to one of the bot's messages
+	Direct     bool // The message was sent in a direct message
+}
+
+// Reason names the rule that triggered a response.
+type Reason string
+
+const (
+	Mention Reason = "mention"
+	Reply   Reason = "reply"
+	Direct  Reason = "direct_message"
+	Name    Reason = "name"
+	Keyword Reason = "keyword"
+	ChimeIn Reason = "chime_in"
+)
+
+// Matcher evaluates compiled rules.
+type Matcher struct {
+	rules    Rules
+	names    []*regexp.Regexp
+	keywords []*regexp.Regexp
+	random   func() float64
+}
+
+// NewMatcher compiles the rules. random returns numbers in [0, 1) and decides
+// chiming in, nil uses math/rand.
+func NewMatcher(rules Rules, random func() float64) (*Matcher, error) {
+	if rules.ChimeIn < 0 || rules.ChimeIn > 1 {
+		return nil, fmt.Errorf("chime_in must be between 0 and 1, got %v", rules.ChimeIn)
+	}
+	if random == nil {
+		random = rand.Float64
+	}
+
+	m := &Matcher{rules: rules, random: random}
+	for _, name := range rules.Names {
+		if name = strings.TrimSpace(name); name != "" {
+			m.names = append(m.names, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(name)))
+		}
+	}
+	for _, keyword := range rules.Keywords {
+		pattern, err := regexp.Compile(keyword)
+		if err != nil {
+			return nil, fmt.Errorf("keyword %q: %w", keyword, err)
+		}
+		m.keywords = append(m.keywords, pattern)
+	}
+	return m, nil
+}
+
+// Match reports whether the message triggers a response and which rule did.
+func (m *Matcher) Match(msg Message) (Reason, bool) {
+	switch {
+	case m.rules.Mentions && msg.Mentioned:
+		return Mention, true
+	case m.rules.Replies && msg.ReplyToBot:
+		return Reply, true
+	case m.rules.Direct && msg.Direct:
+		return Direct, true
+	case m.matchesName(msg.Content):
+		return Name, true
+	}
+	for _, keyword := range m.keywords {
+		if keyword.MatchString(msg.Content) {
+			return Keyword, true
+		}
+	}
+	if m.rules.ChimeIn > 0 && m.random() < m.rules.ChimeIn {
+		return ChimeIn, true
+	}
+	return "", false
+}
+
+// matchesName reports whether the content contains a name as a whole word.
+// Letters and digits of any script continue a word.
+func (m *Matcher) matchesName(content string) bool {
+	for _, name := range m.names {
+		for _, loc := range name.FindAllStringIndex(content, -1) {
+			match := content[loc[0]:loc[1]]
+			if !continuesWord(content[:loc[0]], match) && !continuesWord(match, content[loc[1]:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// continuesWord reports whether the text before and after the boundary
+// belong to the same word.
+func continuesWord(before, after string) bool {
+	last, lastSize := utf8.DecodeLastRuneInString(before)
+	next, nextSize := utf8.DecodeRuneInString(after)
+	return lastSize > 0 && nextSize > 0 && isWordRune(last) && isWordRune(next)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,64 @@
+package triggers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRulesUnmarshalDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Rules
+	}{
+		{"empty", `{}`, DefaultRules()},
+		{"partial", `{"keywords": ["hay"]}`, Rules{Mentions: true, Replies: true, Direct: true, Keywords: []string{"hay"}}},
+		{"disabled", `{"mentions": false, "chime_in": 0.5}`, Rules{Replies: true, Direct: true, ChimeIn: 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules Rules
+			if err := json.Unmarshal([]byte(tt.data), &rules); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(rules, tt.want) {
+				t.Errorf("got %+v, want %+v", rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchNames(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"Neigh!", true},
+		{"hey neigh", true},
+		{"NEIGH, what's up", true},
+		{"hey Neigh_", true},
+		{"Neighbour", false},
+		{"Neighé", false},
+		{"Neigh漢", false},
+		{"éNeigh", false},
+		{"Neigh2", false},
+		{"Neighé and Neigh", true},
+	}
+
+	m, err := NewMatcher(Rules{Names: []string{"Neigh"}}, nil)
+	if err != nil {
+		t.Fatalf("NewMatcher: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			reason, got := m.Match(Message{Content: tt.content})
+			if got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+			if got && reason != Name {
+				t.Errorf("Match(%q) reason = %q, want %q", tt.content, reason, Name)
+			}
+		})
+	}
+}